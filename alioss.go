@@ -16,65 +16,303 @@ package storage
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
 type aliOSSStorage struct {
-	conf   *AliOSSConfig
-	bucket *oss.Bucket
+	conf        *AliOSSConfig
+	bucket      *oss.Bucket
+	credentials *ossCredentials
+	httpClient  *http.Client
+	sdkClient   *http.Client // httpClient with retries
+	retry       *retryPolicy
+	limiter     *RateLimiter
 }
 
 func NewAliOSS(conf *AliOSSConfig) (Storage, error) {
-	client, err := oss.New(conf.Endpoint, conf.AccessKey, conf.Secret)
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newHTTPClient(conf.HTTPClient, conf.ProxyConfig, conf.TLSConfig, conf.TransportConfig)
 	if err != nil {
 		return nil, err
 	}
+	if conf.HTTPClient == nil {
+		// oss disables redirects on the clients it creates itself
+		httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	// the sdk has no retryer, so requests without a body are retried by the transport, and uploads by upload
+	retry := newRetryPolicy(conf.RetryConfig, retryPolicy{
+		maxAttempts: 3,
+		minDelay:    100 * time.Millisecond,
+		maxDelay:    5 * time.Second,
+		statusCodes: statusCodes(http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+	})
+	sdkClient := retry.withAttemptTimeout(httpClient)
+	if sdkClient == httpClient {
+		c := *httpClient
+		sdkClient = &c
+	}
+	sdkClient.Transport = &retryTransport{base: transportOrDefault(sdkClient.Transport), policy: retry}
+
+	provider := conf.CredentialsProvider
+	if provider == nil {
+		provider = &secretCredentials{
+			accessKey: newSecret(conf.AccessKey),
+			secret:    newSecret(conf.Secret),
+		}
+	}
+	credentials := &ossCredentials{provider: provider}
+	if _, err := credentials.GetCredentialsE(); err != nil {
+		return nil, err
+	}
 
-	bucket, err := client.Bucket(conf.Bucket)
+	s := &aliOSSStorage{
+		conf:        conf,
+		credentials: credentials,
+		httpClient:  httpClient,
+		sdkClient:   sdkClient,
+		retry:       retry,
+		limiter:     newConfigRateLimiter(conf.MaxBytesPerSecond, conf.RateLimiter),
+	}
+	if s.bucket, err = s.newBucket(sdkClient); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *aliOSSStorage) newBucket(httpClient *http.Client) (*oss.Bucket, error) {
+	client, err := oss.New(s.conf.Endpoint, "", "", oss.HTTPClient(httpClient), oss.SetCredentialsProvider(s.credentials))
 	if err != nil {
 		return nil, err
 	}
+	return client.Bucket(s.conf.Bucket)
+}
+
+// bucketWithContext returns a bucket whose requests are bound to ctx,
+// for sdk calls which take no context or do not pass it along
+func (s *aliOSSStorage) bucketWithContext(ctx context.Context) (*oss.Bucket, error) {
+	c := *s.sdkClient
+	c.Transport = &contextTransport{base: transportOrDefault(c.Transport), ctx: ctx}
+	return s.newBucket(&c)
+}
+
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
 
-	return &aliOSSStorage{
-		conf:   conf,
-		bucket: bucket,
+// ossCredentials adapts a CredentialsProvider, which the sdk consults on each request
+type ossCredentials struct {
+	provider CredentialsProvider
+}
+
+type ossCredentialValues struct {
+	accessKey     string
+	secret        string
+	securityToken string
+}
+
+func (c ossCredentialValues) GetAccessKeyID() string     { return c.accessKey }
+func (c ossCredentialValues) GetAccessKeySecret() string { return c.secret }
+func (c ossCredentialValues) GetSecurityToken() string   { return c.securityToken }
+
+func (c *ossCredentials) GetCredentialsE() (oss.Credentials, error) {
+	creds, err := c.provider.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ossCredentialValues{
+		accessKey:     creds.AccessKey,
+		secret:        creds.Secret,
+		securityToken: creds.SessionToken,
 	}, nil
 }
 
-func (s *aliOSSStorage) UploadData(data []byte, storagePath, _ string) (string, int64, error) {
-	reader := bytes.NewBuffer(data)
-	if err := s.bucket.PutObject(storagePath, reader); err != nil {
-		return "", 0, err
+// GetCredentials is used by the sdk to sign urls, which cannot fail
+func (c *ossCredentials) GetCredentials() oss.Credentials {
+	credentials, err := c.GetCredentialsE()
+	if err != nil {
+		return ossCredentialValues{}
+	}
+	return credentials
+}
+
+func (s *aliOSSStorage) Capabilities() Capabilities {
+	return Capabilities{
+		PresignedURLs:  true,
+		ServerSideCopy: true,
+		Versioning:     true,
+		BatchDelete:    true,
+		NativeAppend:   true,
+		Lifecycle:      true,
+		CORS:           true,
+	}
+}
+
+func (s *aliOSSStorage) UploadData(data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.uploadData(context.Background(), data, storagePath, contentType, opts...)
+}
+
+func (s *aliOSSStorage) uploadData(ctx context.Context, data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutUpload)
+	defer cancel()
+
+	if err := s.upload(ctx, bytes.NewReader(data), storagePath, contentType, getOptions(ctx, s.limiter, opts)); err != nil {
+		return "", 0, timeoutError(ctx, err)
 	}
 
 	return fmt.Sprintf("https://%s.%s/%s", s.conf.Bucket, s.conf.Endpoint, storagePath), int64(len(data)), nil
 }
 
-func (s *aliOSSStorage) UploadFile(filepath, storagePath, _ string) (string, int64, error) {
-	info, err := os.Stat(filepath)
+func (s *aliOSSStorage) UploadFile(filepath, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	file, err := os.Open(filepath)
 	if err != nil {
 		return "", 0, err
 	}
+	defer file.Close()
 
-	if err = s.bucket.PutObjectFromFile(storagePath, filepath); err != nil {
+	info, err := file.Stat()
+	if err != nil {
 		return "", 0, err
 	}
 
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+
+	if err = s.upload(ctx, file, storagePath, contentType, getOptions(ctx, s.limiter, opts)); err != nil {
+		return "", 0, timeoutError(ctx, err)
+	}
+
 	return fmt.Sprintf("https://%s.%s/%s", s.conf.Bucket, s.conf.Endpoint, storagePath), info.Size(), nil
 }
 
+// upload sends the MD5 of the data for server-side validation when checksums are requested,
+// then checks the CRC64 computed by the service
+func (s *aliOSSStorage) upload(ctx context.Context, reader io.ReadSeeker, storagePath, contentType string, o *options) error {
+	contentType, err := resolveContentType(contentType, storagePath, reader)
+	if err != nil {
+		return err
+	}
+	checksums, err := o.uploadChecksums(reader)
+	if err != nil {
+		return err
+	}
+
+	var header http.Header
+	ossOpts := append(ossOptions(o), oss.ContentType(contentType), oss.WithContext(ctx))
+	if checksums != nil {
+		ossOpts = append(ossOpts,
+			oss.ContentMD5(base64.StdEncoding.EncodeToString(checksums.MD5)),
+			oss.GetResponseHeader(&header),
+		)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = s.bucket.PutObject(storagePath, newThrottledReader(o.ctx, reader, o.limiters), ossOpts...)
+		if err == nil || attempt >= s.retry.maxAttempts || !s.retryable(err) {
+			break
+		}
+		if err = s.retry.wait(ctx, attempt); err != nil {
+			break
+		}
+		if _, err = reader.Seek(0, io.SeekStart); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if checksums != nil {
+		return checksums.verify(ossChecksums(header))
+	}
+	return nil
+}
+
+func (s *aliOSSStorage) retryable(err error) bool {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		return s.retry.retryableStatus(serviceErr.StatusCode)
+	}
+	return isRetryableNetError(err)
+}
+
+// UploadFileResumable uses the oss checkpoint upload, with the checkpoint file stored alongside the journal
+func (s *aliOSSStorage) UploadFileResumable(j *Journal, filepath, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
+	state, file, err := j.prepare(backendAliOSS, s.conf.Bucket, filepath, storagePath, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	// oss reads the file itself
+	_ = file.Close()
+
+	if state.Checkpoint == "" {
+		state.Checkpoint = j.checkpointPath(state)
+		state.PartSize = partSize(state.Size, 8*1024*1024, 10000)
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	o := getOptions(ctx, s.limiter, opts)
+
+	// the checkpoint upload does not pass contexts to its parts
+	bucket, err := s.bucketWithContext(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	ossOpts := append(ossOptions(o), oss.ContentType(state.ContentType), oss.Checkpoint(true, state.Checkpoint))
+	if err = bucket.UploadFile(storagePath, filepath, state.PartSize, ossOpts...); err != nil {
+		return "", 0, err
+	}
+
+	if err = j.Remove(state); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("https://%s.%s/%s", s.conf.Bucket, s.conf.Endpoint, storagePath), state.Size, nil
+}
+
+func (s *aliOSSStorage) ResumeUpload(j *Journal, state *UploadState, opts ...Option) (string, int64, error) {
+	return resumeUpload(s, backendAliOSS, s.conf.Bucket, j, state, opts)
+}
+
 func (s *aliOSSStorage) ListObjects(prefix string) ([]string, error) {
+	return s.listObjects(context.Background(), prefix)
+}
+
+func (s *aliOSSStorage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutList)
+	defer cancel()
+
 	var objects []string
 	marker := oss.Marker("")
 	for {
-		lor, err := s.bucket.ListObjects(oss.Prefix(prefix), marker)
+		lor, err := s.bucket.ListObjects(oss.Prefix(prefix), marker, oss.WithContext(ctx))
 		if err != nil {
-			return nil, err
+			return nil, timeoutError(ctx, err)
 		}
 
 		for _, object := range lor.Objects {
@@ -90,18 +328,46 @@ func (s *aliOSSStorage) ListObjects(prefix string) ([]string, error) {
 	return objects, nil
 }
 
-func (s *aliOSSStorage) DownloadData(storagePath string) ([]byte, error) {
-	reader, err := s.bucket.GetObject(storagePath)
+func (s *aliOSSStorage) DownloadData(storagePath string, opts ...Option) ([]byte, error) {
+	return s.downloadData(context.Background(), storagePath, opts...)
+}
+
+func (s *aliOSSStorage) downloadData(ctx context.Context, storagePath string, opts ...Option) ([]byte, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutDownload)
+	defer cancel()
+
+	o := getOptions(ctx, s.limiter, opts)
+	var header http.Header
+	reader, err := s.bucket.GetObject(storagePath, append(ossOptions(o), oss.GetResponseHeader(&header), oss.WithContext(ctx))...)
 	if err != nil {
-		return nil, err
+		return nil, timeoutError(ctx, err)
 	}
 	defer reader.Close()
 
-	return io.ReadAll(reader)
+	data, err := io.ReadAll(newThrottledReader(o.ctx, reader, o.limiters))
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	if err = o.verifyChecksums(bytes.NewReader(data), ossChecksums(header)); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-func (s *aliOSSStorage) DownloadFile(filepath, storagePath string) (int64, error) {
-	if err := s.bucket.GetObjectToFile(storagePath, filepath); err != nil {
+func (s *aliOSSStorage) DownloadFile(filepath, storagePath string, opts ...Option) (int64, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutDownload)
+	defer cancel()
+
+	o := getOptions(ctx, s.limiter, opts)
+	var header http.Header
+	if len(o.limiters) > 0 {
+		if err := s.downloadThrottled(ctx, filepath, storagePath, &header, o); err != nil {
+			return 0, timeoutError(ctx, err)
+		}
+	} else if err := s.bucket.GetObjectToFile(storagePath, filepath, append(ossOptions(o), oss.GetResponseHeader(&header), oss.WithContext(ctx))...); err != nil {
+		return 0, timeoutError(ctx, err)
+	}
+	if err := o.verifyFile(filepath, ossChecksums(header)); err != nil {
 		return 0, err
 	}
 
@@ -113,15 +379,404 @@ func (s *aliOSSStorage) DownloadFile(filepath, storagePath string) (int64, error
 	return info.Size(), nil
 }
 
+func (s *aliOSSStorage) downloadThrottled(ctx context.Context, filepath, storagePath string, header *http.Header, o *options) error {
+	reader, err := s.bucket.GetObject(storagePath, append(ossOptions(o), oss.GetResponseHeader(header), oss.WithContext(ctx))...)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, newThrottledReader(o.ctx, reader, o.limiters))
+	return err
+}
+
+// ossChecksums returns the checksums reported in a response. Content-MD5 is only set on some objects.
+func ossChecksums(header http.Header) *Checksums {
+	c := &Checksums{}
+	if crc, err := strconv.ParseUint(header.Get(oss.HTTPHeaderOssCRC64), 10, 64); err == nil {
+		c.CRC64 = crc64Bytes(crc)
+	}
+	if md5, err := base64.StdEncoding.DecodeString(header.Get(oss.HTTPHeaderContentMD5)); err == nil && len(md5) > 0 {
+		c.MD5 = md5
+	}
+	return c
+}
+
+func ossOptions(o *options) []oss.Option {
+	var opts []oss.Option
+	if o.progress != nil {
+		opts = append(opts, oss.Progress(&ossProgressListener{fn: o.progress}))
+	}
+	return opts
+}
+
+// Append writes to an appendable object, creating it if needed. Objects uploaded normally cannot be appended to.
+func (s *aliOSSStorage) Append(storagePath string, data []byte) (_ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	var position int64
+	ossOpts := []oss.Option{oss.WithContext(ctx)}
+	meta, err := s.bucket.GetObjectDetailedMeta(storagePath, oss.WithContext(ctx))
+	if err != nil {
+		var serviceErr oss.ServiceError
+		if !errors.As(err, &serviceErr) || serviceErr.StatusCode != http.StatusNotFound {
+			return 0, err
+		}
+		// the first append creates the object
+		ossOpts = append(ossOpts, oss.ContentType(DetectContentType(storagePath, data)))
+	} else if position, err = strconv.ParseInt(meta.Get(oss.HTTPHeaderContentLength), 10, 64); err != nil {
+		return 0, err
+	}
+
+	return s.bucket.AppendObject(storagePath, bytes.NewReader(data), position, ossOpts...)
+}
+
+// ConcatObjects copies the sources into the parts of a multipart upload.
+// Sources under the minimum part size are downloaded and merged with their neighbours.
+func (s *aliOSSStorage) ConcatObjects(dst string, srcs ...string) (err error) {
+	if len(srcs) == 0 {
+		return ErrNoSources
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	withCtx := oss.WithContext(ctx)
+
+	metas := make([]http.Header, len(srcs))
+	sizes := make([]int64, len(srcs))
+	for i, src := range srcs {
+		meta, err := s.bucket.GetObjectDetailedMeta(src, withCtx)
+		if err != nil {
+			return err
+		}
+		if sizes[i], err = strconv.ParseInt(meta.Get(oss.HTTPHeaderContentLength), 10, 64); err != nil {
+			return err
+		}
+		metas[i] = meta
+	}
+
+	contentType := oss.ContentType(metas[0].Get(oss.HTTPHeaderContentType))
+	parts := planConcat(sizes, 100*1024, 5*1024*1024*1024)
+	if len(parts) == 0 {
+		return s.bucket.PutObject(dst, bytes.NewReader(nil), contentType, withCtx)
+	}
+
+	imur, err := s.bucket.InitiateMultipartUpload(dst, contentType, withCtx)
+	if err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		_ = s.bucket.AbortMultipartUpload(imur)
+		return err
+	}
+
+	uploaded := make([]oss.UploadPart, 0, len(parts))
+	for i, part := range parts {
+		var res oss.UploadPart
+		if part.copy {
+			r := part.ranges[0]
+			// fail if a source is modified concurrently
+			res, err = s.bucket.UploadPartCopy(imur, s.conf.Bucket, srcs[r.src], r.offset, r.length, i+1,
+				oss.CopySourceIfMatch(metas[r.src].Get(oss.HTTPHeaderEtag)), withCtx,
+			)
+		} else {
+			var buf []byte
+			for _, r := range part.ranges {
+				var data []byte
+				if data, err = s.downloadRange(ctx, srcs[r.src], metas[r.src].Get(oss.HTTPHeaderEtag), r.offset, r.length); err != nil {
+					return abort(err)
+				}
+				buf = append(buf, data...)
+			}
+			res, err = s.bucket.UploadPart(imur, bytes.NewReader(buf), int64(len(buf)), i+1, withCtx)
+		}
+		if err != nil {
+			return abort(err)
+		}
+		uploaded = append(uploaded, res)
+	}
+
+	if _, err = s.bucket.CompleteMultipartUpload(imur, uploaded, withCtx); err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+func (s *aliOSSStorage) downloadRange(ctx context.Context, storagePath, etag string, offset, length int64) ([]byte, error) {
+	body, err := s.bucket.GetObject(storagePath, oss.Range(offset, offset+length-1), oss.IfMatch(etag), oss.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(body, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 func (s *aliOSSStorage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (string, error) {
 	return s.bucket.SignURL(storagePath, oss.HTTPGet, int64(expiration.Seconds()))
 }
 
 func (s *aliOSSStorage) DeleteObject(storagePath string) error {
-	return s.bucket.DeleteObject(storagePath)
+	return s.deleteObject(context.Background(), storagePath)
+}
+
+func (s *aliOSSStorage) deleteObject(ctx context.Context, storagePath string) error {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	return timeoutError(ctx, s.bucket.DeleteObject(storagePath, oss.WithContext(ctx)))
 }
 
 func (s *aliOSSStorage) DeleteObjects(storagePaths []string) error {
-	_, err := s.bucket.DeleteObjects(storagePaths)
-	return err
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	_, err := s.bucket.DeleteObjects(storagePaths, oss.WithContext(ctx))
+	return timeoutError(ctx, err)
+}
+
+func (s *aliOSSStorage) BucketExists() (bool, error) {
+	return s.bucketExists(context.Background())
+}
+
+func (s *aliOSSStorage) bucketExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	// IsBucketExist takes no options
+	bucket, err := s.bucketWithContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	exists, err := bucket.Client.IsBucketExist(s.conf.Bucket)
+	return exists, timeoutError(ctx, err)
+}
+
+// EnsureBucket creates the bucket. OSS region is determined by the endpoint.
+func (s *aliOSSStorage) EnsureBucket(opts *BucketOptions) (err error) {
+	if opts == nil {
+		opts = &BucketOptions{}
+	}
+	if opts.Region != "" {
+		return ErrNotSupported
+	}
+
+	exists, err := s.BucketExists()
+	if err != nil || exists {
+		return err
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	if err = s.bucket.Client.CreateBucket(s.conf.Bucket, oss.WithContext(ctx)); err != nil {
+		var serviceErr oss.ServiceError
+		if !errors.As(err, &serviceErr) || serviceErr.Code != "BucketAlreadyExists" {
+			return err
+		}
+		// oss reports the conflict for any owner, but only lists buckets of this account
+		owned, existsErr := s.BucketExists()
+		if existsErr != nil {
+			return existsErr
+		}
+		if !owned {
+			return fmt.Errorf("%w: %w", ErrBucketTaken, err)
+		}
+		return nil
+	}
+
+	if !opts.DefaultEncryption && opts.KMSKeyID == "" {
+		return nil
+	}
+
+	rule := oss.ServerEncryptionRule{
+		SSEDefault: oss.SSEDefaultRule{SSEAlgorithm: "AES256"},
+	}
+	if opts.KMSKeyID != "" {
+		rule.SSEDefault.SSEAlgorithm = "KMS"
+		rule.SSEDefault.KMSMasterKeyID = opts.KMSKeyID
+	}
+
+	return s.bucket.Client.SetBucketEncryption(s.conf.Bucket, rule, oss.WithContext(ctx))
+}
+
+func (s *aliOSSStorage) GetLifecycleRules() ([]LifecycleRule, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	rules, _, err := s.lifecycleRules(ctx)
+	return rules, timeoutError(ctx, err)
+}
+
+// lifecycleRules returns the representable rules of the bucket, and whether there were no others
+func (s *aliOSSStorage) lifecycleRules(ctx context.Context) ([]LifecycleRule, bool, error) {
+	res, err := s.bucket.Client.GetBucketLifecycle(s.conf.Bucket, oss.WithContext(ctx))
+	if err != nil {
+		var serviceErr oss.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.Code == "NoSuchLifecycle" {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+
+	rules := make([]LifecycleRule, 0, len(res.Rules))
+	for _, r := range res.Rules {
+		if rule, ok := ossLifecycleRule(r); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, len(rules) == len(res.Rules), nil
+}
+
+// ossLifecycleRule converts r, unless it has settings a LifecycleRule cannot hold
+func ossLifecycleRule(r oss.LifecycleRule) (LifecycleRule, bool) {
+	if r.Status != "Enabled" || len(r.Transitions) > 1 || len(r.Tags) > 0 || r.Filter != nil ||
+		r.NonVersionExpiration != nil || len(r.NonVersionTransitions) > 0 {
+		return LifecycleRule{}, false
+	}
+
+	rule := LifecycleRule{
+		ID:     r.ID,
+		Prefix: r.Prefix,
+	}
+	if r.Expiration != nil {
+		if r.Expiration.Date != "" || r.Expiration.CreatedBeforeDate != "" || r.Expiration.ExpiredObjectDeleteMarker != nil {
+			return LifecycleRule{}, false
+		}
+		rule.ExpireAfterDays = r.Expiration.Days
+	}
+	if len(r.Transitions) == 1 {
+		if r.Transitions[0].CreatedBeforeDate != "" || r.Transitions[0].IsAccessTime != nil {
+			return LifecycleRule{}, false
+		}
+		rule.TransitionAfterDays = r.Transitions[0].Days
+		rule.TransitionStorageClass = string(r.Transitions[0].StorageClass)
+	}
+	if r.AbortMultipartUpload != nil {
+		rule.AbortMultipartAfterDays = r.AbortMultipartUpload.Days
+	}
+	return rule, true
+}
+
+// PutLifecycleRules replaces the rules of the bucket, unless it holds rules GetLifecycleRules leaves out
+func (s *aliOSSStorage) PutLifecycleRules(rules []LifecycleRule) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	if _, complete, err := s.lifecycleRules(ctx); err != nil {
+		return timeoutError(ctx, err)
+	} else if !complete {
+		return ErrLifecycleNotRepresentable
+	}
+
+	// oss rejects an empty configuration, so clearing the rules requires a delete
+	if len(rules) == 0 {
+		return timeoutError(ctx, s.bucket.Client.DeleteBucketLifecycle(s.conf.Bucket, oss.WithContext(ctx)))
+	}
+
+	ossRules := make([]oss.LifecycleRule, 0, len(rules))
+	for i, rule := range rules {
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("rule-%d", i)
+		}
+
+		r := oss.LifecycleRule{
+			ID:     id,
+			Prefix: rule.Prefix,
+			Status: "Enabled",
+		}
+		if rule.ExpireAfterDays > 0 {
+			r.Expiration = &oss.LifecycleExpiration{Days: rule.ExpireAfterDays}
+		}
+		if rule.TransitionAfterDays > 0 {
+			r.Transitions = []oss.LifecycleTransition{{
+				Days:         rule.TransitionAfterDays,
+				StorageClass: oss.StorageClassType(rule.TransitionStorageClass),
+			}}
+		}
+		if rule.AbortMultipartAfterDays > 0 {
+			r.AbortMultipartUpload = &oss.LifecycleAbortMultipartUpload{Days: rule.AbortMultipartAfterDays}
+		}
+		ossRules = append(ossRules, r)
+	}
+
+	return timeoutError(ctx, s.bucket.Client.SetBucketLifecycle(s.conf.Bucket, ossRules, oss.WithContext(ctx)))
+}
+
+func (s *aliOSSStorage) GetCORSRules() ([]CORSRule, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	res, err := s.bucket.Client.GetBucketCORS(s.conf.Bucket, oss.WithContext(ctx))
+	if err != nil {
+		var serviceErr oss.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.Code == "NoSuchCORSConfiguration" {
+			return nil, nil
+		}
+		return nil, timeoutError(ctx, err)
+	}
+
+	rules := make([]CORSRule, 0, len(res.CORSRules))
+	for _, r := range res.CORSRules {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: r.AllowedOrigin,
+			AllowedMethods: r.AllowedMethod,
+			AllowedHeaders: r.AllowedHeader,
+			ExposedHeaders: r.ExposeHeader,
+			MaxAgeSeconds:  r.MaxAgeSeconds,
+		})
+	}
+
+	return rules, nil
+}
+
+func (s *aliOSSStorage) SetCORSRules(rules []CORSRule) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	// oss rejects an empty configuration, so clearing the rules requires a delete
+	if len(rules) == 0 {
+		return timeoutError(ctx, s.bucket.Client.DeleteBucketCORS(s.conf.Bucket, oss.WithContext(ctx)))
+	}
+
+	ossRules := make([]oss.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		ossRules = append(ossRules, oss.CORSRule{
+			AllowedOrigin: rule.AllowedOrigins,
+			AllowedMethod: rule.AllowedMethods,
+			AllowedHeader: rule.AllowedHeaders,
+			ExposeHeader:  rule.ExposedHeaders,
+			MaxAgeSeconds: rule.MaxAgeSeconds,
+		})
+	}
+
+	return timeoutError(ctx, s.bucket.Client.SetBucketCORS(s.conf.Bucket, ossRules, oss.WithContext(ctx)))
+}
+
+func (s *aliOSSStorage) Validate(ctx context.Context) (*ValidationReport, error) {
+	return validate(ctx, s)
+}
+
+func (s *aliOSSStorage) Close() error {
+	// a configured client is left to its owner
+	if s.conf.HTTPClient == nil {
+		s.httpClient.CloseIdleConnections()
+	}
+	return nil
 }
@@ -15,13 +15,21 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
@@ -30,23 +38,46 @@ type azureBLOBStorage struct {
 	container    string
 	containerUrl azblob.ContainerURL
 	serviceUrl   azblob.ServiceURL
+	credential   *azureCredential
+	tokens       bool // whether the credentials provider supplies oauth tokens, used for presigned urls
+	httpClient   *http.Client
+	retry        *retryPolicy
+	limiter      *RateLimiter
 }
 
 func NewAzure(conf *AzureConfig) (Storage, error) {
-	credential, err := azblob.NewSharedKeyCredential(
-		conf.AccountName,
-		conf.AccountKey,
-	)
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	provider := conf.CredentialsProvider
+	if provider == nil {
+		provider = &secretCredentials{accountKey: newSecret(conf.AccountKey)}
+	}
+	credential := newAzureCredential(conf.AccountName, provider)
+	creds, err := credential.check()
 	if err != nil {
 		return nil, err
 	}
 
-	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{
+	httpClient, err := newHTTPClient(conf.HTTPClient, conf.ProxyConfig, conf.TLSConfig, conf.TransportConfig)
+	if err != nil {
+		return nil, err
+	}
+	retry := newRetryPolicy(conf.RetryConfig, retryPolicy{
+		maxAttempts: 5,
+		maxDelay:    5 * time.Second,
+	})
+	// zero values keep the sdk defaults, which always randomize the backoff
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{
 		Retry: azblob.RetryOptions{
 			Policy:        azblob.RetryPolicyExponential,
-			MaxTries:      5,
-			MaxRetryDelay: time.Second * 5,
+			MaxTries:      int32(retry.maxAttempts),
+			TryTimeout:    retry.attemptTimeout,
+			RetryDelay:    retry.minDelay,
+			MaxRetryDelay: retry.maxDelay,
 		},
+		HTTPSender: newAzureHTTPSender(httpClient, retry),
 	})
 
 	sUrl := fmt.Sprintf("https://%s.blob.core.windows.net", conf.AccountName)
@@ -64,26 +95,157 @@ func NewAzure(conf *AzureConfig) (Storage, error) {
 	return &azureBLOBStorage{
 		conf:         conf,
 		container:    sUrl,
-		serviceUrl:   azblob.NewServiceURL(*serviceUrl, pipeline),
-		containerUrl: azblob.NewContainerURL(*containerUrl, pipeline),
+		serviceUrl:   azblob.NewServiceURL(*serviceUrl, p),
+		containerUrl: azblob.NewContainerURL(*containerUrl, p),
+		credential:   credential,
+		tokens:       creds.Token != "",
+		httpClient:   httpClient,
+		retry:        retry,
+		limiter:      newConfigRateLimiter(conf.MaxBytesPerSecond, conf.RateLimiter),
 	}, nil
 }
 
-func (s *azureBLOBStorage) UploadData(data []byte, storagePath, contentType string) (string, int64, error) {
-	blobUrl := s.containerUrl.NewBlockBlobURL(storagePath)
-	_, err := azblob.UploadBufferToBlockBlob(context.Background(), data, blobUrl, azblob.UploadToBlockBlobOptions{
-		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
-		BlockSize:       4 * 1024 * 1024,
-		Parallelism:     16,
+func (s *azureBLOBStorage) Capabilities() Capabilities {
+	return Capabilities{
+		PresignedURLs:  s.conf.TokenCredential != nil || s.tokens,
+		ServerSideCopy: true,
+		Versioning:     true,
+		NativeAppend:   true,
+		CORS:           true,
+	}
+}
+
+// azureCredential consults a CredentialsProvider on each request, signing with the account key when there is one,
+// or else authorizing with an oauth token. The shared key credential is re-created when the key changes.
+type azureCredential struct {
+	azblob.Credential // only provides the unexported marker method, New is overridden
+
+	accountName string
+	provider    CredentialsProvider
+
+	mu         sync.Mutex
+	accountKey string
+	sharedKey  *azblob.SharedKeyCredential
+}
+
+func newAzureCredential(accountName string, provider CredentialsProvider) *azureCredential {
+	return &azureCredential{
+		Credential:  azblob.NewAnonymousCredential(),
+		accountName: accountName,
+		provider:    provider,
+	}
+}
+
+// check fetches the initial credentials, to fail early on bad keys
+func (c *azureCredential) check() (*Credentials, error) {
+	creds, err := c.provider.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if creds.AccountKey != "" {
+		_, err = c.getSharedKey(creds.AccountKey)
+	} else if creds.Token == "" {
+		err = errors.New("azure: credentials provider returned neither an account key nor a token")
+	}
+	return creds, err
+}
+
+func (c *azureCredential) getSharedKey(accountKey string) (*azblob.SharedKeyCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sharedKey == nil || accountKey != c.accountKey {
+		sharedKey, err := azblob.NewSharedKeyCredential(c.accountName, accountKey)
+		if err != nil {
+			return nil, err
+		}
+		c.sharedKey = sharedKey
+		c.accountKey = accountKey
+	}
+	return c.sharedKey, nil
+}
+
+func (c *azureCredential) New(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.Policy {
+	return pipeline.PolicyFunc(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		creds, err := c.provider.Credentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var credential azblob.Credential
+		if creds.AccountKey != "" {
+			if credential, err = c.getSharedKey(creds.AccountKey); err != nil {
+				return nil, err
+			}
+		} else {
+			credential = azblob.NewTokenCredential(creds.Token, nil)
+		}
+		return credential.New(next, po).Do(ctx, request)
 	})
+}
+
+// newAzureHTTPSender sends pipeline requests through the storage's own http client.
+// Responses with a configured retryable status fail with a temporary error, which the retry policy retries.
+func newAzureHTTPSender(client *http.Client, retry *retryPolicy) pipeline.Factory {
+	return pipeline.FactoryFunc(func(_ pipeline.Policy, _ *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			resp, err := client.Do(request.WithContext(ctx))
+			if err != nil {
+				return pipeline.NewHTTPResponse(resp), pipeline.NewError(err, "HTTP request failed")
+			}
+			if retry.retryableStatus(resp.StatusCode) {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				return pipeline.NewHTTPResponse(nil), pipeline.NewError(&retryableStatusError{status: resp.Status}, "HTTP request failed")
+			}
+			return pipeline.NewHTTPResponse(resp), nil
+		}
+	})
+}
+
+func (s *azureBLOBStorage) UploadData(data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.uploadData(context.Background(), data, storagePath, contentType, opts...)
+}
+
+func (s *azureBLOBStorage) uploadData(ctx context.Context, data []byte, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	o := getOptions(ctx, s.limiter, opts)
+	size := int64(len(data))
+	blobUrl := s.containerUrl.NewBlockBlobURL(storagePath)
+
+	contentType, err = resolveContentType(contentType, storagePath, bytes.NewReader(data))
+	if err != nil {
+		return "", 0, err
+	}
+	checksums, err := o.uploadChecksums(bytes.NewReader(data))
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch {
+	case checksums != nil:
+		err = s.uploadBlocks(ctx, bytes.NewReader(data), size, blobUrl, contentType, checksums, o)
+	case len(o.limiters) > 0:
+		err = s.uploadStream(ctx, bytes.NewReader(data), size, blobUrl, contentType, o)
+	default:
+		_, err = azblob.UploadBufferToBlockBlob(ctx, data, blobUrl, azblob.UploadToBlockBlobOptions{
+			BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
+			BlockSize:       4 * 1024 * 1024,
+			Parallelism:     16,
+			Progress:        azureProgress(size, o.progress),
+		})
+	}
 	if err != nil {
 		return "", 0, err
 	}
 
-	return fmt.Sprintf("%s/%s", s.container, storagePath), int64(len(data)), nil
+	return fmt.Sprintf("%s/%s", s.container, storagePath), size, nil
 }
 
-func (s *azureBLOBStorage) UploadFile(filepath, storagePath, contentType string) (string, int64, error) {
+func (s *azureBLOBStorage) UploadFile(filepath, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return "", 0, err
@@ -97,30 +259,159 @@ func (s *azureBLOBStorage) UploadFile(filepath, storagePath, contentType string)
 		return "", 0, err
 	}
 
-	// upload blocks in parallel for optimal performance
-	// it calls PutBlock/PutBlockList for files larger than 256 MBs and PutBlob for smaller files
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	if contentType, err = resolveContentType(contentType, storagePath, file); err != nil {
+		return "", 0, err
+	}
+	o := getOptions(ctx, s.limiter, opts)
+	checksums, err := o.uploadChecksums(file)
+	if err != nil {
+		return "", 0, err
+	}
+
 	blobUrl := s.containerUrl.NewBlockBlobURL(storagePath)
-	_, err = azblob.UploadFileToBlockBlob(context.Background(), file, blobUrl, azblob.UploadToBlockBlobOptions{
+	switch {
+	case checksums != nil:
+		err = s.uploadBlocks(ctx, file, stat.Size(), blobUrl, contentType, checksums, o)
+	case len(o.limiters) > 0:
+		err = s.uploadStream(ctx, file, stat.Size(), blobUrl, contentType, o)
+	default:
+		// upload blocks in parallel for optimal performance
+		// it calls PutBlock/PutBlockList for files larger than 256 MBs and PutBlob for smaller files
+		_, err = azblob.UploadFileToBlockBlob(ctx, file, blobUrl, azblob.UploadToBlockBlobOptions{
+			BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
+			BlockSize:       4 * 1024 * 1024,
+			Parallelism:     16,
+			Progress:        azureProgress(stat.Size(), o.progress),
+		})
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	return fmt.Sprintf("%s/%s", s.container, storagePath), stat.Size(), nil
+}
+
+// uploadStream is used for throttled uploads, which need to read the source sequentially
+func (s *azureBLOBStorage) uploadStream(ctx context.Context, reader io.Reader, size int64, blobUrl azblob.BlockBlobURL, contentType string, o *options) error {
+	_, err := azblob.UploadStreamToBlockBlob(ctx, o.reader(reader, size), blobUrl, azblob.UploadStreamToBlockBlobOptions{
+		BufferSize:      4 * 1024 * 1024,
+		MaxBuffers:      16,
 		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
-		BlockSize:       4 * 1024 * 1024,
-		Parallelism:     16,
 	})
+	return err
+}
+
+// uploadBlocks stages blocks sequentially, each validated by the service against its MD5,
+// and stores the MD5 of the whole blob for download verification
+func (s *azureBLOBStorage) uploadBlocks(ctx context.Context, reader io.Reader, size int64, blobUrl azblob.BlockBlobURL, contentType string, checksums *Checksums, o *options) error {
+	reader = o.reader(reader, size)
+	buf := make([]byte, 4*1024*1024)
+
+	var blockIDs []string
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		sum := md5.Sum(buf[:n])
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blockIDs))))
+		if _, err = blobUrl.StageBlock(ctx, blockID, bytes.NewReader(buf[:n]), azblob.LeaseAccessConditions{}, sum[:], azblob.ClientProvidedKeyOptions{}); err != nil {
+			return err
+		}
+		blockIDs = append(blockIDs, blockID)
+		if n < len(buf) {
+			break
+		}
+	}
+
+	_, err := blobUrl.CommitBlockList(ctx, blockIDs,
+		azblob.BlobHTTPHeaders{ContentType: contentType, ContentMD5: checksums.MD5}, azblob.Metadata{}, azblob.BlobAccessConditions{},
+		azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{},
+	)
+	return err
+}
+
+func (s *azureBLOBStorage) UploadFileResumable(j *Journal, filepath, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
+	state, file, err := j.prepare(backendAzure, s.conf.ContainerName, filepath, storagePath, contentType)
 	if err != nil {
 		return "", 0, err
 	}
+	defer file.Close()
 
-	return fmt.Sprintf("%s/%s", s.container, storagePath), stat.Size(), nil
+	if state.PartSize == 0 {
+		state.PartSize = partSize(state.Size, 4*1024*1024, azblob.BlockBlobMaxBlocks)
+	}
+
+	// staged blocks are kept by azure for a week until they are committed
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	o := getOptions(ctx, s.limiter, opts)
+	blobUrl := s.containerUrl.NewBlockBlobURL(storagePath)
+	for int64(len(state.BlockIDs))*state.PartSize < state.Size {
+		offset := int64(len(state.BlockIDs)) * state.PartSize
+		buf, err := readPart(file, offset, min(state.PartSize, state.Size-offset), o)
+		if err != nil {
+			return "", 0, err
+		}
+
+		// block ids must all have the same length
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(state.BlockIDs))))
+		if _, err = blobUrl.StageBlock(ctx, blockID, bytes.NewReader(buf), azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{}); err != nil {
+			return "", 0, err
+		}
+
+		state.BlockIDs = append(state.BlockIDs, blockID)
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+		if o.progress != nil {
+			o.progress(offset+int64(len(buf)), state.Size)
+		}
+	}
+
+	_, err = blobUrl.CommitBlockList(ctx, state.BlockIDs,
+		azblob.BlobHTTPHeaders{ContentType: state.ContentType}, azblob.Metadata{}, azblob.BlobAccessConditions{},
+		azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{},
+	)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err = j.Remove(state); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%s/%s", s.container, storagePath), state.Size, nil
+}
+
+func (s *azureBLOBStorage) ResumeUpload(j *Journal, state *UploadState, opts ...Option) (string, int64, error) {
+	return resumeUpload(s, backendAzure, s.conf.ContainerName, j, state, opts)
 }
 
 func (s *azureBLOBStorage) ListObjects(prefix string) ([]string, error) {
+	return s.listObjects(context.Background(), prefix)
+}
+
+func (s *azureBLOBStorage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutList)
+	defer cancel()
+
 	var objects []string
 
 	for marker := (azblob.Marker{}); marker.NotDone(); {
-		listBlob, err := s.containerUrl.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{
+		listBlob, err := s.containerUrl.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
 			Prefix: prefix,
 		})
 		if err != nil {
-			return nil, err
+			return nil, timeoutError(ctx, err)
 		}
 
 		marker = listBlob.NextMarker
@@ -132,42 +423,99 @@ func (s *azureBLOBStorage) ListObjects(prefix string) ([]string, error) {
 	return objects, nil
 }
 
-func (s *azureBLOBStorage) DownloadData(storagePath string) ([]byte, error) {
-	b := make([]byte, 0)
+func (s *azureBLOBStorage) DownloadData(storagePath string, opts ...Option) ([]byte, error) {
+	return s.downloadData(context.Background(), storagePath, opts...)
+}
+
+func (s *azureBLOBStorage) downloadData(ctx context.Context, storagePath string, opts ...Option) (_ []byte, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutDownload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
 
+	o := getOptions(ctx, s.limiter, opts)
 	blobUrl := s.containerUrl.NewBlobURL(storagePath)
-	err := azblob.DownloadBlobToBuffer(context.Background(), blobUrl, 0, azblob.CountToEnd, b, azblob.DownloadFromBlobOptions{
+	if len(o.limiters) > 0 {
+		var buf bytes.Buffer
+		_, expected, err := s.downloadStream(ctx, &buf, blobUrl, o)
+		if err != nil {
+			return nil, err
+		}
+		if err = o.verifyChecksums(bytes.NewReader(buf.Bytes()), expected); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	props, err := blobUrl.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the buffer must be large enough to hold the whole blob
+	size := props.ContentLength()
+	b := make([]byte, size)
+	err = azblob.DownloadBlobToBuffer(ctx, blobUrl, 0, size, b, azblob.DownloadFromBlobOptions{
 		BlockSize:   4 * 1024 * 1024,
 		Parallelism: 16,
 		RetryReaderOptionsPerBlock: azblob.RetryReaderOptions{
 			MaxRetryRequests: 3,
 		},
+		Progress: azureProgress(size, o.progress),
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err = o.verifyChecksums(bytes.NewReader(b), azureChecksums(props.ContentMD5())); err != nil {
+		return nil, err
+	}
 
 	return b, nil
 }
 
-func (s *azureBLOBStorage) DownloadFile(filepath, storagePath string) (int64, error) {
+func (s *azureBLOBStorage) DownloadFile(filepath, storagePath string, opts ...Option) (_ int64, err error) {
 	file, err := os.Create(filepath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutDownload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	o := getOptions(ctx, s.limiter, opts)
 	blobUrl := s.containerUrl.NewBlobURL(storagePath)
-	err = azblob.DownloadBlobToFile(context.Background(), blobUrl, 0, 0, file, azblob.DownloadFromBlobOptions{
+	if len(o.limiters) > 0 {
+		n, expected, err := s.downloadStream(ctx, file, blobUrl, o)
+		if err != nil {
+			return 0, err
+		}
+		if err = o.verifyFile(filepath, expected); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	props, err := blobUrl.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	size := props.ContentLength()
+	err = azblob.DownloadBlobToFile(ctx, blobUrl, 0, size, file, azblob.DownloadFromBlobOptions{
 		BlockSize:   4 * 1024 * 1024,
 		Parallelism: 16,
 		RetryReaderOptionsPerBlock: azblob.RetryReaderOptions{
 			MaxRetryRequests: 3,
 		},
+		Progress: azureProgress(size, o.progress),
 	})
 	if err != nil {
 		return 0, err
 	}
+	if err = o.verifyFile(filepath, azureChecksums(props.ContentMD5())); err != nil {
+		return 0, err
+	}
 
 	stat, err := file.Stat()
 	if err != nil {
@@ -176,17 +524,184 @@ func (s *azureBLOBStorage) DownloadFile(filepath, storagePath string) (int64, er
 	return stat.Size(), nil
 }
 
-func (s *azureBLOBStorage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (string, error) {
-	if s.conf.TokenCredential == nil {
+// downloadStream is used for throttled downloads, which need to write the destination sequentially
+func (s *azureBLOBStorage) downloadStream(ctx context.Context, w io.Writer, blobUrl azblob.BlobURL, o *options) (int64, *Checksums, error) {
+	resp, err := blobUrl.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+
+	n, err := io.Copy(w, o.reader(body, resp.ContentLength()))
+	if err != nil {
+		return 0, nil, err
+	}
+	// the whole blob was requested, so Content-MD5 is the blob's stored MD5
+	return n, azureChecksums(resp.ContentMD5()), nil
+}
+
+// azureChecksums returns the stored MD5 of a blob, which is only set by some uploads
+func azureChecksums(contentMD5 []byte) *Checksums {
+	if len(contentMD5) == 0 {
+		return nil
+	}
+	return &Checksums{MD5: contentMD5}
+}
+
+func azureProgress(total int64, fn ProgressFunc) pipeline.ProgressReceiver {
+	if fn == nil {
+		return nil
+	}
+	return func(bytesTransferred int64) {
+		fn(bytesTransferred, total)
+	}
+}
+
+// Append writes to an append blob, creating it if needed. Existing block blobs cannot be appended to.
+func (s *azureBLOBStorage) Append(storagePath string, data []byte) (_ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	blobUrl := s.containerUrl.NewAppendBlobURL(storagePath)
+
+	_, err = blobUrl.Create(ctx, azblob.BlobHTTPHeaders{ContentType: DetectContentType(storagePath, data)}, azblob.Metadata{},
+		azblob.BlobAccessConditions{ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny}},
+		nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{},
+	)
+	if err != nil {
+		var storageErr azblob.StorageError
+		if !errors.As(err, &storageErr) || storageErr.ServiceCode() != azblob.ServiceCodeBlobAlreadyExists {
+			return 0, err
+		}
+	}
+
+	for len(data) > 0 {
+		n := min(len(data), azblob.AppendBlobMaxAppendBlockBytes)
+		if _, err = blobUrl.AppendBlock(ctx, bytes.NewReader(data[:n]), azblob.AppendBlobAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{}); err != nil {
+			return 0, err
+		}
+		data = data[n:]
+	}
+
+	props, err := blobUrl.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return props.ContentLength(), nil
+}
+
+// azureMaxBlockFromURL is the maximum size of a block staged from a url
+const azureMaxBlockFromURL = 100 * 1024 * 1024
+
+// ConcatObjects stages ranges of the sources as blocks of dst, read by the service through
+// short-lived SAS urls, then commits them.
+func (s *azureBLOBStorage) ConcatObjects(dst string, srcs ...string) (err error) {
+	if len(srcs) == 0 {
+		return ErrNoSources
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	now := time.Now()
+	sasCredential, err := s.sasCredential(ctx, now.Add(-time.Minute), now.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	dstUrl := s.containerUrl.NewBlockBlobURL(dst)
+
+	var blockIDs []string
+	var headers azblob.BlobHTTPHeaders
+	for i, src := range srcs {
+		props, err := s.containerUrl.NewBlobURL(src).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			headers = props.NewHTTPHeaders()
+			headers.ContentMD5 = nil
+		}
+
+		qp, err := azblob.BlobSASSignatureValues{
+			Protocol:      azblob.SASProtocolHTTPS,
+			StartTime:     now.Add(-time.Minute),
+			ExpiryTime:    now.Add(time.Hour),
+			Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+			ContainerName: s.conf.ContainerName,
+			BlobName:      src,
+		}.NewSASQueryParameters(sasCredential)
+		if err != nil {
+			return err
+		}
+		srcUrl := s.containerUrl.NewBlobURL(src).URL()
+		srcUrl.RawQuery = qp.Encode()
+
+		// fail if a source is modified concurrently
+		sourceConditions := azblob.ModifiedAccessConditions{IfMatch: props.ETag()}
+		size := props.ContentLength()
+		for offset := int64(0); offset < size; offset += azureMaxBlockFromURL {
+			blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blockIDs))))
+			_, err = dstUrl.StageBlockFromURL(ctx, blockID, srcUrl, offset, min(azureMaxBlockFromURL, size-offset),
+				azblob.LeaseAccessConditions{}, sourceConditions, azblob.ClientProvidedKeyOptions{}, nil,
+			)
+			if err != nil {
+				return err
+			}
+			blockIDs = append(blockIDs, blockID)
+		}
+	}
+
+	_, err = dstUrl.CommitBlockList(ctx, blockIDs, headers, azblob.Metadata{}, azblob.BlobAccessConditions{},
+		azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{},
+	)
+	return err
+}
+
+// sasCredential returns the credential signing SAS urls valid from start to expiry: the current account key,
+// or else a user delegation key, obtained with the oauth token of the credentials provider
+func (s *azureBLOBStorage) sasCredential(ctx context.Context, start, expiry time.Time) (azblob.StorageAccountCredential, error) {
+	creds, err := s.credential.provider.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if creds.AccountKey != "" {
+		return s.credential.getSharedKey(creds.AccountKey)
+	}
+	return s.serviceUrl.GetUserDelegationCredential(ctx, azblob.NewKeyInfo(start, expiry), nil, nil)
+}
+
+func (s *azureBLOBStorage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (_ string, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutPresign)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	tokenCredential := s.conf.TokenCredential
+	if tokenCredential == nil && s.tokens {
+		creds, err := s.credential.provider.Credentials(ctx)
+		if err != nil {
+			return "", err
+		}
+		if creds.Token != "" {
+			tokenCredential = azblob.NewTokenCredential(creds.Token, nil)
+		}
+	}
+	if tokenCredential == nil {
 		return "", errors.New("OAuth required")
 	}
 
 	now := time.Now()
 	exp := now.Add(expiration)
 
-	serviceUrl := s.serviceUrl.WithPipeline(azblob.NewPipeline(s.conf.TokenCredential, azblob.PipelineOptions{}))
+	serviceUrl := s.serviceUrl.WithPipeline(azblob.NewPipeline(tokenCredential, azblob.PipelineOptions{
+		HTTPSender: newAzureHTTPSender(s.httpClient, s.retry),
+	}))
 	udc, err := serviceUrl.GetUserDelegationCredential(
-		context.Background(), azblob.NewKeyInfo(now, exp), nil, nil,
+		ctx, azblob.NewKeyInfo(now, exp), nil, nil,
 	)
 	if err != nil {
 		return "", err
@@ -196,7 +711,7 @@ func (s *azureBLOBStorage) GeneratePresignedUrl(storagePath string, expiration t
 		Protocol:      azblob.SASProtocolHTTPS,
 		StartTime:     now,
 		ExpiryTime:    exp,
-		Permissions:   azblob.AccountSASPermissions{Read: true}.String(),
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
 		ContainerName: s.conf.ContainerName,
 		BlobName:      storagePath,
 	}.NewSASQueryParameters(udc)
@@ -204,13 +719,23 @@ func (s *azureBLOBStorage) GeneratePresignedUrl(storagePath string, expiration t
 		return "", err
 	}
 
-	return fmt.Sprintf("https://%s.blob.core.windows.net?%s", s.conf.AccountName, qp.Encode()), nil
+	// the sas only grants access to the blob it was signed for
+	blobUrl := s.containerUrl.NewBlobURL(storagePath).URL()
+	blobUrl.RawQuery = qp.Encode()
+	return blobUrl.String(), nil
 }
 
 func (s *azureBLOBStorage) DeleteObject(storagePath string) error {
+	return s.deleteObject(context.Background(), storagePath)
+}
+
+func (s *azureBLOBStorage) deleteObject(ctx context.Context, storagePath string) error {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
 	blobUrl := s.containerUrl.NewBlobURL(storagePath)
-	_, err := blobUrl.Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
-	return err
+	_, err := blobUrl.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	return timeoutError(ctx, err)
 }
 
 func (s *azureBLOBStorage) DeleteObjects(storagePaths []string) error {
@@ -221,3 +746,125 @@ func (s *azureBLOBStorage) DeleteObjects(storagePaths []string) error {
 	}
 	return nil
 }
+
+func (s *azureBLOBStorage) BucketExists() (bool, error) {
+	return s.bucketExists(context.Background())
+}
+
+func (s *azureBLOBStorage) bucketExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	_, err := s.containerUrl.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		var storageErr azblob.StorageError
+		if errors.As(err, &storageErr) && storageErr.ServiceCode() == azblob.ServiceCodeContainerNotFound {
+			return false, nil
+		}
+		return false, timeoutError(ctx, err)
+	}
+
+	return true, nil
+}
+
+// EnsureBucket creates the container. Azure region is determined by the storage account,
+// and blobs are always encrypted at rest, so only the default options are accepted.
+func (s *azureBLOBStorage) EnsureBucket(opts *BucketOptions) error {
+	if opts != nil && (opts.Region != "" || opts.KMSKeyID != "") {
+		return ErrNotSupported
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	_, err := s.containerUrl.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	if err != nil {
+		var storageErr azblob.StorageError
+		if errors.As(err, &storageErr) && storageErr.ServiceCode() == azblob.ServiceCodeContainerAlreadyExists {
+			return nil
+		}
+		return timeoutError(ctx, err)
+	}
+
+	return nil
+}
+
+// GetLifecycleRules is not supported, azure lifecycle management policies are only exposed through the management API
+func (s *azureBLOBStorage) GetLifecycleRules() ([]LifecycleRule, error) {
+	return nil, ErrNotSupported
+}
+
+// PutLifecycleRules is not supported, azure lifecycle management policies are only exposed through the management API
+func (s *azureBLOBStorage) PutLifecycleRules(_ []LifecycleRule) error {
+	return ErrNotSupported
+}
+
+// GetCORSRules returns the blob service CORS rules, which apply to every container in the account
+func (s *azureBLOBStorage) GetCORSRules() ([]CORSRule, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	props, err := s.serviceUrl.GetProperties(ctx)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+
+	rules := make([]CORSRule, 0, len(props.Cors))
+	for _, c := range props.Cors {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: splitAzureList(c.AllowedOrigins),
+			AllowedMethods: splitAzureList(c.AllowedMethods),
+			AllowedHeaders: splitAzureList(c.AllowedHeaders),
+			ExposedHeaders: splitAzureList(c.ExposedHeaders),
+			MaxAgeSeconds:  int(c.MaxAgeInSeconds),
+		})
+	}
+
+	return rules, nil
+}
+
+// SetCORSRules replaces the blob service CORS rules, which apply to every container in the account.
+// Omitted service properties are left unchanged, so the rules cannot be cleared this way.
+func (s *azureBLOBStorage) SetCORSRules(rules []CORSRule) error {
+	if len(rules) == 0 {
+		return ErrNotSupported
+	}
+
+	cors := make([]azblob.CorsRule, 0, len(rules))
+	for _, rule := range rules {
+		cors = append(cors, azblob.CorsRule{
+			AllowedOrigins:  strings.Join(rule.AllowedOrigins, ","),
+			AllowedMethods:  strings.Join(rule.AllowedMethods, ","),
+			AllowedHeaders:  strings.Join(rule.AllowedHeaders, ","),
+			ExposedHeaders:  strings.Join(rule.ExposedHeaders, ","),
+			MaxAgeInSeconds: int32(rule.MaxAgeSeconds),
+		})
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	_, err := s.serviceUrl.SetProperties(ctx, azblob.StorageServiceProperties{
+		Cors: cors,
+	})
+	return timeoutError(ctx, err)
+}
+
+func splitAzureList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func (s *azureBLOBStorage) Validate(ctx context.Context) (*ValidationReport, error) {
+	return validate(ctx, s)
+}
+
+func (s *azureBLOBStorage) Close() error {
+	// a configured client is left to its owner
+	if s.conf.HTTPClient == nil {
+		s.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
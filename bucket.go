@@ -0,0 +1,28 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "errors"
+
+// ErrBucketTaken is returned by EnsureBucket when the bucket name belongs to another account
+var ErrBucketTaken = errors.New("bucket name is taken by another account")
+
+// BucketOptions are applied by EnsureBucket when the bucket needs to be created.
+// Existing buckets, including ones created concurrently, are left untouched: their encryption is not changed.
+type BucketOptions struct {
+	Region            string `yaml:"region,omitempty"`             // s3 region or gcp location, defaults to the configured region
+	DefaultEncryption bool   `yaml:"default_encryption,omitempty"` // enable provider-managed default encryption
+	KMSKeyID          string `yaml:"kms_key_id,omitempty"`         // s3/oss kms key id or gcp kms key name, implies default encryption
+}
@@ -0,0 +1,27 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+// Capabilities describes the features supported by a storage instance.
+// Operations backing an unsupported feature return ErrNotSupported or a provider error.
+type Capabilities struct {
+	PresignedURLs  bool // GeneratePresignedUrl returns an http(s) url usable by third parties
+	ServerSideCopy bool // objects can be copied without passing through this process
+	Versioning     bool // the provider can keep previous versions of overwritten objects
+	BatchDelete    bool // DeleteObjects removes many objects per request
+	NativeAppend   bool // Append extends objects in place, rather than recomposing them
+	Lifecycle      bool // GetLifecycleRules and PutLifecycleRules are supported
+	CORS           bool // GetCORSRules and SetCORSRules are supported
+}
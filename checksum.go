@@ -0,0 +1,153 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"os"
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+// Checksums of an object's content. CRCs are big-endian.
+// When verifying, nil fields are unknown and skipped.
+type Checksums struct {
+	MD5    []byte
+	CRC32C []byte
+	CRC64  []byte // ECMA polynomial, as used by AliOSS
+	SHA256 []byte
+}
+
+// IntegrityError is returned when transferred data does not match the checksum reported by the provider
+type IntegrityError struct {
+	Algorithm string
+	Expected  []byte
+	Actual    []byte
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %x, got %x", e.Algorithm, e.Expected, e.Actual)
+}
+
+func computeChecksums(r io.Reader) (*Checksums, error) {
+	m, c32, c64, s := md5.New(), crc32.New(crc32cTable), crc64.New(crc64Table), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(m, c32, c64, s), r); err != nil {
+		return nil, err
+	}
+	return &Checksums{
+		MD5:    m.Sum(nil),
+		CRC32C: c32.Sum(nil),
+		CRC64:  c64.Sum(nil),
+		SHA256: s.Sum(nil),
+	}, nil
+}
+
+type checksumCheck struct {
+	algorithm        string
+	expected, actual []byte
+}
+
+// verify compares the checksums known on both sides
+func (c *Checksums) verify(expected *Checksums) error {
+	if expected == nil {
+		return nil
+	}
+	for _, check := range []checksumCheck{
+		{"MD5", expected.MD5, c.MD5},
+		{"CRC32C", expected.CRC32C, c.CRC32C},
+		{"CRC64", expected.CRC64, c.CRC64},
+		{"SHA-256", expected.SHA256, c.SHA256},
+	} {
+		if check.expected != nil && check.actual != nil && !bytes.Equal(check.expected, check.actual) {
+			return &IntegrityError{Algorithm: check.algorithm, Expected: check.expected, Actual: check.actual}
+		}
+	}
+	return nil
+}
+
+func crc32cBytes(crc uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, crc)
+}
+
+func crc64Bytes(crc uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, crc)
+}
+
+// WithChecksums computes the MD5, CRC32C, CRC64 and SHA-256 of the transferred data into c.
+// Uploads send the checksums supported by the provider for server-side validation,
+// and downloads are verified against the checksums stored by the provider, failing with an *IntegrityError.
+func WithChecksums(c *Checksums) Option {
+	return func(o *options) {
+		o.checksums = c
+	}
+}
+
+// uploadChecksums computes the checksums of r when requested, then rewinds it
+func (o *options) uploadChecksums(r io.ReadSeeker) (*Checksums, error) {
+	if o.checksums == nil {
+		return nil, nil
+	}
+
+	c, err := computeChecksums(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	*o.checksums = *c
+	return c, nil
+}
+
+// verifyChecksums checks downloaded data against the checksums reported by the provider, when requested
+func (o *options) verifyChecksums(r io.Reader, expected *Checksums) error {
+	if o.checksums == nil {
+		return nil
+	}
+
+	c, err := computeChecksums(r)
+	if err != nil {
+		return err
+	}
+
+	*o.checksums = *c
+	return c.verify(expected)
+}
+
+// verifyFile checks a downloaded file against the checksums reported by the provider, when requested
+func (o *options) verifyFile(filepath string, expected *Checksums) error {
+	if o.checksums == nil {
+		return nil
+	}
+
+	file, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return o.verifyChecksums(file, expected)
+}
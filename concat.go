@@ -0,0 +1,89 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "errors"
+
+var ErrNoSources = errors.New("no source objects to concatenate")
+
+// concatRange is a byte range of one of the concatenation sources
+type concatRange struct {
+	src    int
+	offset int64
+	length int64
+}
+
+// concatPart is a single part of a multipart concatenation.
+// Copied parts hold a single range copied server-side, others are downloaded and uploaded.
+type concatPart struct {
+	copy   bool
+	ranges []concatRange
+}
+
+// planConcat splits the sources into multipart upload parts, respecting the provider part size limits.
+// Sources too small to be copied as a part are merged with their neighbours through this process,
+// and only up to minPart bytes are ever buffered for a single part.
+func planConcat(sizes []int64, minPart, maxPart int64) []concatPart {
+	var parts []concatPart
+	var pending []concatRange
+	var pendingSize int64
+	flush := func() {
+		if len(pending) > 0 {
+			parts = append(parts, concatPart{ranges: pending})
+			pending, pendingSize = nil, 0
+		}
+	}
+
+	for i, size := range sizes {
+		var offset int64
+		if pendingSize > 0 {
+			// top up the pending part with the head of this source
+			n := min(minPart-pendingSize, size)
+			pending = append(pending, concatRange{src: i, offset: 0, length: n})
+			pendingSize += n
+			offset = n
+			if pendingSize >= minPart {
+				flush()
+			}
+		}
+
+		remaining := size - offset
+		switch {
+		case remaining == 0:
+			continue
+
+		case remaining >= minPart || i == len(sizes)-1:
+			// split evenly, so no copied part ends up under the minimum
+			n := (remaining + maxPart - 1) / maxPart
+			chunk := (remaining + n - 1) / n
+			for remaining > 0 {
+				length := min(chunk, remaining)
+				parts = append(parts, concatPart{
+					copy:   true,
+					ranges: []concatRange{{src: i, offset: offset, length: length}},
+				})
+				offset += length
+				remaining -= length
+			}
+
+		default:
+			pending = append(pending, concatRange{src: i, offset: offset, length: remaining})
+			pendingSize += remaining
+		}
+	}
+	flush()
+
+	return parts
+}
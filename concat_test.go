@@ -0,0 +1,87 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanConcat(t *testing.T) {
+	copied := func(src int, offset, length int64) concatPart {
+		return concatPart{copy: true, ranges: []concatRange{{src: src, offset: offset, length: length}}}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		sizes    []int64
+		expected []concatPart
+	}{
+		{
+			name:     "empty source list",
+			sizes:    nil,
+			expected: nil,
+		},
+		{
+			name:     "single source",
+			sizes:    []int64{7},
+			expected: []concatPart{copied(0, 0, 7)},
+		},
+		{
+			name:     "single source over the maximum",
+			sizes:    []int64{250},
+			expected: []concatPart{copied(0, 0, 84), copied(0, 84, 84), copied(0, 168, 82)},
+		},
+		{
+			name:  "small part in the middle",
+			sizes: []int64{10, 2, 10},
+			expected: []concatPart{
+				copied(0, 0, 10),
+				{ranges: []concatRange{{src: 1, offset: 0, length: 2}, {src: 2, offset: 0, length: 3}}},
+				copied(2, 3, 7),
+			},
+		},
+		{
+			name:     "small last part",
+			sizes:    []int64{10, 2},
+			expected: []concatPart{copied(0, 0, 10), copied(1, 0, 2)},
+		},
+		{
+			name:  "small parts only",
+			sizes: []int64{2, 2},
+			expected: []concatPart{
+				{ranges: []concatRange{{src: 0, offset: 0, length: 2}, {src: 1, offset: 0, length: 2}}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := planConcat(tc.sizes, 5, 100)
+			require.Equal(t, tc.expected, parts)
+
+			// every byte of every source is covered once, in order
+			covered := make([]int64, len(tc.sizes))
+			for _, part := range parts {
+				for _, r := range part.ranges {
+					require.Equal(t, covered[r.src], r.offset)
+					covered[r.src] += r.length
+				}
+			}
+			for i, size := range tc.sizes {
+				require.Equal(t, size, covered[i])
+			}
+		})
+	}
+}
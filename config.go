@@ -15,58 +15,449 @@
 package storage
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
+var ErrInvalidConfig = errors.New("invalid storage config")
+
+// StorageConfig selects a backend, exactly one of which must be set
+type StorageConfig struct {
+	S3     *S3Config     `yaml:"s3,omitempty"`
+	GCP    *GCPConfig    `yaml:"gcp,omitempty"`
+	Azure  *AzureConfig  `yaml:"azure,omitempty"`
+	AliOSS *AliOSSConfig `yaml:"alioss,omitempty"`
+	Local  *LocalConfig  `yaml:"local,omitempty"`
+}
+
 type AliOSSConfig struct {
-	AccessKey string `yaml:"access_key,omitempty"`
-	Secret    string `yaml:"secret,omitempty"`
+	AccessKey string `yaml:"access_key,omitempty"` // accepts secret references, see ResolveSecret
+	Secret    string `yaml:"secret,omitempty"`     // accepts secret references
 	Endpoint  string `yaml:"endpoint,omitempty"`
 	Bucket    string `yaml:"bucket,omitempty"`
+
+	ProxyConfig     *ProxyConfig     `yaml:"proxy_config,omitempty"`
+	TLSConfig       *TLSConfig       `yaml:"tls_config,omitempty"`
+	TransportConfig *TransportConfig `yaml:"transport_config,omitempty"`
+	HTTPClient      *http.Client     `yaml:"-"` // used instead of a client built from the proxy, tls and transport configs
+	RetryConfig     *RetryConfig     `yaml:"retry_config,omitempty"`
+	TimeoutConfig   *TimeoutConfig   `yaml:"timeout_config,omitempty"`
+
+	CredentialsProvider CredentialsProvider `yaml:"-"` // rotating credentials, takes precedence over access_key and secret
+
+	MaxBytesPerSecond int          `yaml:"max_bytes_per_second,omitempty"` // bandwidth limit shared by all transfers of this storage
+	RateLimiter       *RateLimiter `yaml:"-"`                              // shared limiter, takes precedence over MaxBytesPerSecond
 }
 
 type AzureConfig struct {
 	AccountName     string                 `yaml:"account_name,omitempty"` // (env AZURE_STORAGE_ACCOUNT)
-	AccountKey      string                 `yaml:"account_key,omitempty"`  // (env AZURE_STORAGE_KEY)
+	AccountKey      string                 `yaml:"account_key,omitempty"`  // (env AZURE_STORAGE_KEY), accepts secret references, see ResolveSecret
 	ContainerName   string                 `yaml:"container_name,omitempty"`
 	TokenCredential azblob.TokenCredential `yaml:"-"` // required for presigned url generation
+	ProxyConfig     *ProxyConfig           `yaml:"proxy_config,omitempty"`
+	TLSConfig       *TLSConfig             `yaml:"tls_config,omitempty"`
+	TransportConfig *TransportConfig       `yaml:"transport_config,omitempty"`
+	HTTPClient      *http.Client           `yaml:"-"` // used instead of a client built from the proxy, tls and transport configs
+	RetryConfig     *RetryConfig           `yaml:"retry_config,omitempty"`
+	TimeoutConfig   *TimeoutConfig         `yaml:"timeout_config,omitempty"`
+
+	// CredentialsProvider supplies rotating credentials, taking precedence over account_key.
+	// When it returns a token instead of a key, it is also used for presigned urls.
+	CredentialsProvider CredentialsProvider `yaml:"-"`
+
+	MaxBytesPerSecond int          `yaml:"max_bytes_per_second,omitempty"` // bandwidth limit shared by all transfers of this storage
+	RateLimiter       *RateLimiter `yaml:"-"`                              // shared limiter, takes precedence over MaxBytesPerSecond
 }
 
 type GCPConfig struct {
-	CredentialsJSON string       `yaml:"credentials_json,omitempty"` // (env GOOGLE_APPLICATION_CREDENTIALS)
-	Bucket          string       `yaml:"bucket,omitempty"`
-	ProxyConfig     *ProxyConfig `yaml:"proxy_config,omitempty"`
+	CredentialsJSON string           `yaml:"credentials_json,omitempty"` // (env GOOGLE_APPLICATION_CREDENTIALS), accepts secret references, see ResolveSecret
+	Bucket          string           `yaml:"bucket,omitempty"`
+	ProjectID       string           `yaml:"project_id,omitempty"` // only used for bucket creation, defaults to the credentials project
+	ProxyConfig     *ProxyConfig     `yaml:"proxy_config,omitempty"`
+	TLSConfig       *TLSConfig       `yaml:"tls_config,omitempty"`
+	TransportConfig *TransportConfig `yaml:"transport_config,omitempty"`
+	HTTPClient      *http.Client     `yaml:"-"` // used instead of a client built from the proxy, tls and transport configs, and wrapped for authorization
+	RetryConfig     *RetryConfig     `yaml:"retry_config,omitempty"`
+	TimeoutConfig   *TimeoutConfig   `yaml:"timeout_config,omitempty"`
+
+	CredentialsProvider CredentialsProvider `yaml:"-"` // rotating credentials, takes precedence over credentials_json
+
+	MaxBytesPerSecond int          `yaml:"max_bytes_per_second,omitempty"` // bandwidth limit shared by all transfers of this storage
+	RateLimiter       *RateLimiter `yaml:"-"`                              // shared limiter, takes precedence over MaxBytesPerSecond
 }
 
 type LocalConfig struct {
 	StorageDir string `yaml:"storage_dir,omitempty"`
+
+	MaxBytesPerSecond int          `yaml:"max_bytes_per_second,omitempty"` // bandwidth limit shared by all transfers of this storage
+	RateLimiter       *RateLimiter `yaml:"-"`                              // shared limiter, takes precedence over MaxBytesPerSecond
 }
 
 type S3Config struct {
-	AccessKey            string       `yaml:"access_key,omitempty"`
-	Secret               string       `yaml:"secret,omitempty"`
-	SessionToken         string       `yaml:"session_token,omitempty"`
-	AssumeRoleArn        string       `yaml:"assume_role_arn,omitempty"`         // ARN of the role to assume for file upload. Egress will make an AssumeRole API call using the provided access_key and secret to assume that role
-	AssumeRoleExternalId string       `yaml:"assume_role_external_id,omitempty"` // ExternalID to use when assuming role for upload
-	Region               string       `yaml:"region,omitempty"`
-	Endpoint             string       `yaml:"endpoint,omitempty"`
-	Bucket               string       `yaml:"bucket,omitempty"`
-	ForcePathStyle       bool         `yaml:"force_path_style,omitempty"`
-	ProxyConfig          *ProxyConfig `yaml:"proxy_config,omitempty"`
-
-	MaxRetries    int           `yaml:"max_retries,omitempty"`
+	AccessKey            string           `yaml:"access_key,omitempty"`              // accepts secret references, see ResolveSecret
+	Secret               string           `yaml:"secret,omitempty"`                  // accepts secret references
+	SessionToken         string           `yaml:"session_token,omitempty"`           // accepts secret references
+	AssumeRoleArn        string           `yaml:"assume_role_arn,omitempty"`         // ARN of the role to assume for file upload. Egress will make an AssumeRole API call using the provided access_key and secret to assume that role
+	AssumeRoleExternalId string           `yaml:"assume_role_external_id,omitempty"` // ExternalID to use when assuming role for upload
+	Region               string           `yaml:"region,omitempty"`
+	Endpoint             string           `yaml:"endpoint,omitempty"`
+	Bucket               string           `yaml:"bucket,omitempty"`
+	ForcePathStyle       bool             `yaml:"force_path_style,omitempty"`
+	ProxyConfig          *ProxyConfig     `yaml:"proxy_config,omitempty"`
+	TLSConfig            *TLSConfig       `yaml:"tls_config,omitempty"`
+	TransportConfig      *TransportConfig `yaml:"transport_config,omitempty"`
+	HTTPClient           *http.Client     `yaml:"-"` // used instead of a client built from the proxy, tls and transport configs, AWS_CA_BUNDLE is then ignored
+	RetryConfig          *RetryConfig     `yaml:"retry_config,omitempty"`
+	TimeoutConfig        *TimeoutConfig   `yaml:"timeout_config,omitempty"`
+
+	// Deprecated: use RetryConfig, these are used when it is not set
+	MaxRetries    int           `yaml:"max_retries,omitempty"` // number of attempts, including the first
 	MaxRetryDelay time.Duration `yaml:"max_retry_delay,omitempty"`
 	MinRetryDelay time.Duration `yaml:"min_retry_delay,omitempty"`
 
 	Metadata           map[string]string `yaml:"metadata,omitempty"`
 	Tagging            string            `yaml:"tagging,omitempty"`
 	ContentDisposition string            `yaml:"content_disposition,omitempty"`
+
+	CredentialsProvider CredentialsProvider `yaml:"-"` // rotating credentials, takes precedence over access_key, secret and session_token
+
+	MaxBytesPerSecond int          `yaml:"max_bytes_per_second,omitempty"` // bandwidth limit shared by all transfers of this storage
+	RateLimiter       *RateLimiter `yaml:"-"`                              // shared limiter, takes precedence over MaxBytesPerSecond
 }
 
 type ProxyConfig struct {
 	Url      string `yaml:"url,omitempty"`
 	Username string `yaml:"username,omitempty"`
-	Password string `yaml:"password,omitempty"`
+	Password string `yaml:"password,omitempty"` // accepts secret references, see ResolveSecret
+
+	// NoProxy lists hosts reached directly, with the syntax of the NO_PROXY environment variable:
+	// host names (also matching subdomains), .domain suffixes, ip addresses, cidr ranges, optional :port, or *.
+	// Loopback addresses are never proxied.
+	NoProxy []string `yaml:"no_proxy,omitempty"`
+}
+
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`              // pem bundle trusted in addition to the system roots
+	CA                 string `yaml:"ca,omitempty"`                   // inline pem, trusted in addition to the system roots
+	CertFile           string `yaml:"cert_file,omitempty"`            // client certificate for mutual tls, reloaded when it changes
+	KeyFile            string `yaml:"key_file,omitempty"`             // client certificate key
+	MinVersion         string `yaml:"min_version,omitempty"`          // 1.0, 1.1, 1.2 or 1.3
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // disables certificate verification, for test setups only
+}
+
+// RetryConfig controls how failed requests are retried, zero values keep the defaults of each backend.
+// Backoff doubles from MinDelay up to MaxDelay. Network errors, throttling and server errors are always retried.
+type RetryConfig struct {
+	MaxAttempts          int           `yaml:"max_attempts,omitempty"` // including the first, 1 disables retries
+	MinDelay             time.Duration `yaml:"min_delay,omitempty"`
+	MaxDelay             time.Duration `yaml:"max_delay,omitempty"`
+	DisableJitter        bool          `yaml:"disable_jitter,omitempty"`         // not supported by the gcp and azure sdks, which always randomize delays
+	RetryableStatusCodes []int         `yaml:"retryable_status_codes,omitempty"` // retried in addition to the defaults
+	AttemptTimeout       time.Duration `yaml:"attempt_timeout,omitempty"`        // bounds each attempt, including its transfer
+}
+
+// TimeoutConfig bounds each operation, including its retries, zero values disable the timeout.
+// Operations exceeding it fail with an error matching ErrTimeout.
+type TimeoutConfig struct {
+	Connect  time.Duration `yaml:"connect,omitempty"`  // requests made while creating the storage, such as the s3 region lookup
+	Metadata time.Duration `yaml:"metadata,omitempty"` // object and bucket metadata, deletes and bucket settings
+	List     time.Duration `yaml:"list,omitempty"`     // each ListObjects call, across all pages
+	Upload   time.Duration `yaml:"upload,omitempty"`   // each upload, append or concat, including all of its parts
+	Download time.Duration `yaml:"download,omitempty"` // each download, including reading the data
+	Presign  time.Duration `yaml:"presign,omitempty"`  // may fetch credentials or a user delegation key
+}
+
+// TransportConfig tunes the http transport, zero values keep the defaults of http.DefaultTransport
+type TransportConfig struct {
+	DialTimeout           time.Duration `yaml:"dial_timeout,omitempty"`
+	KeepAlive             time.Duration `yaml:"keep_alive,omitempty"` // tcp keep-alive period, negative to disable
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout,omitempty"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout,omitempty"`
+	MaxIdleConns          int           `yaml:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host,omitempty"`
+	DisableKeepAlives     bool          `yaml:"disable_keep_alives,omitempty"` // use each connection for a single request
+	DisableHTTP2          bool          `yaml:"disable_http2,omitempty"`
+	UserAgent             string        `yaml:"user_agent,omitempty"` // prepended to the user agent of the sdk
+}
+
+// Validate checks that exactly one backend is set, and validates it
+func (c *StorageConfig) Validate() error {
+	var validators []func() error
+	if c.S3 != nil {
+		validators = append(validators, c.S3.Validate)
+	}
+	if c.GCP != nil {
+		validators = append(validators, c.GCP.Validate)
+	}
+	if c.Azure != nil {
+		validators = append(validators, c.Azure.Validate)
+	}
+	if c.AliOSS != nil {
+		validators = append(validators, c.AliOSS.Validate)
+	}
+	if c.Local != nil {
+		validators = append(validators, c.Local.Validate)
+	}
+
+	switch len(validators) {
+	case 0:
+		return ErrNoBackend
+	case 1:
+		return validators[0]()
+	default:
+		return ErrMultipleBackends
+	}
+}
+
+// Validate reports every problem with the config, without making any request
+func (c *AliOSSConfig) Validate() error {
+	var errs configErrors
+	if c.CredentialsProvider == nil {
+		errs.required("alioss", "access_key", c.AccessKey)
+		errs.required("alioss", "secret", c.Secret)
+	}
+	errs.required("alioss", "endpoint", c.Endpoint)
+	errs.required("alioss", "bucket", c.Bucket)
+	if c.Endpoint != "" {
+		if _, err := url.Parse(c.Endpoint); err != nil {
+			errs.add("alioss: invalid endpoint: %v", err)
+		}
+	}
+	errs.proxy("alioss", c.ProxyConfig)
+	errs.tls("alioss", c.TLSConfig)
+	errs.transport("alioss", c.TransportConfig)
+	errs.httpClient("alioss", c.HTTPClient, c.ProxyConfig, c.TLSConfig, c.TransportConfig)
+	errs.retry("alioss", c.RetryConfig)
+	errs.timeout("alioss", c.TimeoutConfig)
+	return errs.err()
+}
+
+// Validate reports every problem with the config, without making any request
+func (c *AzureConfig) Validate() error {
+	var errs configErrors
+	errs.required("azure", "account_name", c.AccountName)
+	if c.CredentialsProvider == nil {
+		errs.required("azure", "account_key", c.AccountKey)
+	}
+	errs.required("azure", "container_name", c.ContainerName)
+	if c.AccountKey != "" && !isSecretRef(c.AccountKey) {
+		if _, err := base64.StdEncoding.DecodeString(c.AccountKey); err != nil {
+			errs.add("azure: account_key is not valid base64")
+		}
+	}
+	errs.proxy("azure", c.ProxyConfig)
+	errs.tls("azure", c.TLSConfig)
+	errs.transport("azure", c.TransportConfig)
+	errs.httpClient("azure", c.HTTPClient, c.ProxyConfig, c.TLSConfig, c.TransportConfig)
+	errs.retry("azure", c.RetryConfig)
+	errs.timeout("azure", c.TimeoutConfig)
+	return errs.err()
+}
+
+// Validate reports every problem with the config, without making any request
+func (c *GCPConfig) Validate() error {
+	var errs configErrors
+	errs.required("gcp", "bucket", c.Bucket)
+	if c.CredentialsJSON != "" && !isSecretRef(c.CredentialsJSON) && !json.Valid([]byte(c.CredentialsJSON)) {
+		errs.add("gcp: credentials_json is not valid json")
+	}
+	errs.proxy("gcp", c.ProxyConfig)
+	errs.tls("gcp", c.TLSConfig)
+	errs.transport("gcp", c.TransportConfig)
+	errs.httpClient("gcp", c.HTTPClient, c.ProxyConfig, c.TLSConfig, c.TransportConfig)
+	errs.retry("gcp", c.RetryConfig)
+	errs.timeout("gcp", c.TimeoutConfig)
+	return errs.err()
+}
+
+// Validate reports every problem with the config, without making any request
+func (c *LocalConfig) Validate() error {
+	return nil
+}
+
+// Validate reports every problem with the config, without making any request
+func (c *S3Config) Validate() error {
+	var errs configErrors
+	errs.required("s3", "bucket", c.Bucket)
+	if (c.AccessKey == "") != (c.Secret == "") {
+		errs.add("s3: access_key and secret must be set together")
+	}
+	if c.SessionToken != "" && c.AccessKey == "" {
+		errs.add("s3: session_token requires access_key and secret")
+	}
+	if c.AssumeRoleExternalId != "" && c.AssumeRoleArn == "" {
+		errs.add("s3: assume_role_external_id requires assume_role_arn")
+	}
+	if c.AssumeRoleArn != "" && c.Endpoint != "" {
+		errs.add("s3: assume_role_arn cannot be used with a custom endpoint")
+	}
+	if c.Endpoint != "" {
+		errs.url("s3", "endpoint", c.Endpoint, "http", "https")
+	}
+	errs.proxy("s3", c.ProxyConfig)
+	errs.tls("s3", c.TLSConfig)
+	errs.transport("s3", c.TransportConfig)
+	errs.httpClient("s3", c.HTTPClient, c.ProxyConfig, c.TLSConfig, c.TransportConfig)
+	errs.retry("s3", c.RetryConfig)
+	errs.timeout("s3", c.TimeoutConfig)
+	if c.RetryConfig != nil && (c.MaxRetries != 0 || c.MaxRetryDelay != 0 || c.MinRetryDelay != 0) {
+		errs.add("s3: max_retries, max_retry_delay and min_retry_delay cannot be combined with retry_config")
+	}
+
+	if c.MaxRetries < 0 {
+		errs.add("s3: max_retries must not be negative")
+	}
+	if c.MaxRetryDelay < 0 {
+		errs.add("s3: max_retry_delay must not be negative")
+	}
+	if c.MinRetryDelay < 0 {
+		errs.add("s3: min_retry_delay must not be negative")
+	}
+	if c.MaxRetryDelay > 0 && c.MinRetryDelay > c.MaxRetryDelay {
+		errs.add("s3: min_retry_delay %s exceeds max_retry_delay %s", c.MinRetryDelay, c.MaxRetryDelay)
+	}
+	return errs.err()
+}
+
+// configErrors collects every problem found in a config
+type configErrors []error
+
+func (e *configErrors) add(format string, args ...any) {
+	*e = append(*e, fmt.Errorf(format, args...))
+}
+
+func (e *configErrors) required(backend, field, value string) {
+	if value == "" {
+		e.add("%s: %s is required", backend, field)
+	}
+}
+
+func (e *configErrors) url(backend, field, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	switch {
+	case err != nil:
+		e.add("%s: invalid %s: %v", backend, field, err)
+	case !slices.Contains(schemes, u.Scheme):
+		e.add("%s: %s must be a %s url, got %q", backend, field, strings.Join(schemes, " or "), value)
+	case u.Host == "":
+		e.add("%s: %s is missing a host", backend, field)
+	}
+}
+
+func (e *configErrors) proxy(backend string, conf *ProxyConfig) {
+	if conf == nil {
+		return
+	}
+	e.required(backend, "proxy_config.url", conf.Url)
+	if conf.Url != "" {
+		e.url(backend, "proxy_config.url", conf.Url, "http", "https", "socks5")
+	}
+	if conf.Password != "" && conf.Username == "" {
+		e.add("%s: proxy_config.password requires a username", backend)
+	}
+}
+
+func (e *configErrors) tls(backend string, conf *TLSConfig) {
+	if conf == nil {
+		return
+	}
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		e.add("%s: tls_config.cert_file and tls_config.key_file must be set together", backend)
+	}
+	if _, ok := tlsVersions[conf.MinVersion]; conf.MinVersion != "" && !ok {
+		e.add("%s: tls_config.min_version must be one of 1.0, 1.1, 1.2 or 1.3", backend)
+	}
+}
+
+func (e *configErrors) transport(backend string, conf *TransportConfig) {
+	if conf == nil {
+		return
+	}
+	for _, field := range []durationField{
+		{"dial_timeout", conf.DialTimeout},
+		{"tls_handshake_timeout", conf.TLSHandshakeTimeout},
+		{"response_header_timeout", conf.ResponseHeaderTimeout},
+		{"idle_conn_timeout", conf.IdleConnTimeout},
+	} {
+		if field.value < 0 {
+			e.add("%s: transport_config.%s must not be negative", backend, field.name)
+		}
+	}
+	if conf.MaxIdleConns < 0 || conf.MaxIdleConnsPerHost < 0 || conf.MaxConnsPerHost < 0 {
+		e.add("%s: transport_config connection limits must not be negative", backend)
+	}
+}
+
+func (e *configErrors) retry(backend string, conf *RetryConfig) {
+	if conf == nil {
+		return
+	}
+	if conf.MaxAttempts < 0 {
+		e.add("%s: retry_config.max_attempts must not be negative", backend)
+	}
+	for _, field := range []durationField{
+		{"min_delay", conf.MinDelay},
+		{"max_delay", conf.MaxDelay},
+		{"attempt_timeout", conf.AttemptTimeout},
+	} {
+		if field.value < 0 {
+			e.add("%s: retry_config.%s must not be negative", backend, field.name)
+		}
+	}
+	if conf.MaxDelay > 0 && conf.MinDelay > conf.MaxDelay {
+		e.add("%s: retry_config.min_delay %s exceeds max_delay %s", backend, conf.MinDelay, conf.MaxDelay)
+	}
+	for _, code := range conf.RetryableStatusCodes {
+		if code < 100 || code > 599 {
+			e.add("%s: retry_config.retryable_status_codes contains invalid status %d", backend, code)
+		}
+	}
+}
+
+func (e *configErrors) timeout(backend string, conf *TimeoutConfig) {
+	if conf == nil {
+		return
+	}
+	for _, field := range []durationField{
+		{"connect", conf.Connect},
+		{"metadata", conf.Metadata},
+		{"list", conf.List},
+		{"upload", conf.Upload},
+		{"download", conf.Download},
+		{"presign", conf.Presign},
+	} {
+		if field.value < 0 {
+			e.add("%s: timeout_config.%s must not be negative", backend, field.name)
+		}
+	}
+}
+
+type durationField struct {
+	name  string
+	value time.Duration
+}
+
+// httpClient reports settings that would be ignored in favor of a custom client
+func (e *configErrors) httpClient(backend string, client *http.Client, proxy *ProxyConfig, tlsConf *TLSConfig, transportConf *TransportConfig) {
+	if client != nil && (proxy != nil || tlsConf != nil || transportConf != nil) {
+		e.add("%s: proxy_config, tls_config and transport_config cannot be combined with a custom http client", backend)
+	}
+}
+
+func (e configErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(e...))
 }
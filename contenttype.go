@@ -0,0 +1,76 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType
+const sniffLen = 512
+
+// contentTypes covers the formats we write, which system mime tables often lack or get wrong
+var contentTypes = map[string]string{
+	".m3u8": "application/x-mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".ogg":  "audio/ogg",
+	".webm": "video/webm",
+	".json": "application/json",
+	".txt":  "text/plain; charset=utf-8",
+}
+
+// DetectContentType infers a content type from the extension of storagePath,
+// falling back to sniffing the first bytes of the content
+func DetectContentType(storagePath string, head []byte) string {
+	if contentType := extensionContentType(storagePath); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(head)
+}
+
+func extensionContentType(storagePath string) string {
+	ext := strings.ToLower(path.Ext(storagePath))
+	if contentType, ok := contentTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// resolveContentType detects the content type of r when none was given, then rewinds it
+func resolveContentType(contentType, storagePath string, r io.ReadSeeker) (string, error) {
+	if contentType != "" {
+		return contentType, nil
+	}
+	if contentType = extensionContentType(storagePath); contentType != "" {
+		return contentType, nil
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+// CORSRule is a provider-agnostic bucket CORS rule.
+type CORSRule struct {
+	AllowedOrigins []string `yaml:"allowed_origins,omitempty"`
+	AllowedMethods []string `yaml:"allowed_methods,omitempty"`
+	AllowedHeaders []string `yaml:"allowed_headers,omitempty"` // not supported by GCP, which allows all request headers
+	ExposedHeaders []string `yaml:"exposed_headers,omitempty"`
+	MaxAgeSeconds  int      `yaml:"max_age_seconds,omitempty"`
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// credentialsRefreshInterval bounds how long credentials without an expiry are reused before the provider is consulted again
+const credentialsRefreshInterval = 10 * time.Second
+
+// CredentialsProvider supplies credentials that can rotate during the lifetime of a Storage.
+// Storages consult it per request, reusing the result until it expires, so in-flight uploads are not interrupted.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (*Credentials, error)
+}
+
+// Credentials holds the values used by each backend, others are ignored
+type Credentials struct {
+	AccessKey       string `yaml:"access_key,omitempty"`       // s3, alioss
+	Secret          string `yaml:"secret,omitempty"`           // s3, alioss
+	SessionToken    string `yaml:"session_token,omitempty"`    // s3, alioss
+	AccountKey      string `yaml:"account_key,omitempty"`      // azure
+	CredentialsJSON string `yaml:"credentials_json,omitempty"` // gcp
+	Token           string `yaml:"token,omitempty"`            // oauth access token for gcp or azure, used when no key is set
+
+	// Expires is when the credentials must be fetched again. When zero, they are re-checked after a few seconds.
+	Expires time.Time `yaml:"expires,omitempty"`
+}
+
+func (c *Credentials) expires() time.Time {
+	if c.Expires.IsZero() {
+		return time.Now().Add(credentialsRefreshInterval)
+	}
+	return c.Expires
+}
+
+// secretCredentials provides the credentials of a config, resolving secret references on each call
+type secretCredentials struct {
+	accessKey       *secret
+	secret          *secret
+	sessionToken    *secret
+	accountKey      *secret
+	credentialsJSON *secret
+}
+
+func (c *secretCredentials) Credentials(_ context.Context) (*Credentials, error) {
+	creds := &Credentials{}
+	for _, field := range []secretField{
+		{c.accessKey, &creds.AccessKey},
+		{c.secret, &creds.Secret},
+		{c.sessionToken, &creds.SessionToken},
+		{c.accountKey, &creds.AccountKey},
+		{c.credentialsJSON, &creds.CredentialsJSON},
+	} {
+		if field.secret == nil {
+			continue
+		}
+		value, err := field.secret.get()
+		if err != nil {
+			return nil, err
+		}
+		*field.dst = value
+	}
+	return creds, nil
+}
+
+type secretField struct {
+	secret *secret
+	dst    *string
+}
+
+// fileCredentials reads credentials from a file, parsing it again whenever it changes
+type fileCredentials struct {
+	file *secret
+
+	mu       sync.Mutex
+	contents string
+	creds    *Credentials
+}
+
+// NewFileCredentialsProvider returns a provider reading a yaml file with the fields of Credentials,
+// or a google service account key. The file is checked for changes on each call, following symlinks,
+// so kubernetes secret mounts can be used directly.
+func NewFileCredentialsProvider(filename string) CredentialsProvider {
+	return &fileCredentials{file: newSecret(secretFilePrefix + filename)}
+}
+
+func (c *fileCredentials) Credentials(_ context.Context) (*Credentials, error) {
+	contents, err := c.file.get()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds == nil || contents != c.contents {
+		creds := &Credentials{}
+		if err = yaml.Unmarshal([]byte(contents), creds); err != nil {
+			return nil, fmt.Errorf("credentials file: %w", err)
+		}
+		if *creds == (Credentials{}) && json.Valid([]byte(contents)) {
+			creds.CredentialsJSON = contents
+		}
+		c.contents = contents
+		c.creds = creds
+	}
+
+	// copied, since callers may hold on to it
+	creds := *c.creds
+	return &creds, nil
+}
@@ -0,0 +1,417 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by the LoadEnv methods, each preceded by the prefix passed to them.
+// Unset variables leave the config unchanged.
+//
+//	S3_ACCESS_KEY, S3_SECRET, S3_SESSION_TOKEN, S3_ASSUME_ROLE_ARN, S3_ASSUME_ROLE_EXTERNAL_ID,
+//	S3_REGION, S3_ENDPOINT, S3_BUCKET, S3_FORCE_PATH_STYLE, S3_PROXY_URL, S3_PROXY_USERNAME, S3_PROXY_PASSWORD,
+//	S3_MAX_RETRIES, S3_MAX_RETRY_DELAY, S3_MIN_RETRY_DELAY, S3_METADATA, S3_TAGGING, S3_CONTENT_DISPOSITION,
+//	S3_MAX_BYTES_PER_SECOND
+//
+//	GCP_CREDENTIALS_JSON, GCP_BUCKET, GCP_PROJECT_ID, GCP_PROXY_URL, GCP_PROXY_USERNAME, GCP_PROXY_PASSWORD,
+//	GCP_MAX_BYTES_PER_SECOND
+//
+//	AZURE_ACCOUNT_NAME, AZURE_ACCOUNT_KEY, AZURE_CONTAINER_NAME, AZURE_PROXY_URL, AZURE_PROXY_USERNAME,
+//	AZURE_PROXY_PASSWORD, AZURE_MAX_BYTES_PER_SECOND
+//
+//	ALIOSS_ACCESS_KEY, ALIOSS_SECRET, ALIOSS_ENDPOINT, ALIOSS_BUCKET, ALIOSS_PROXY_URL, ALIOSS_PROXY_USERNAME,
+//	ALIOSS_PROXY_PASSWORD, ALIOSS_MAX_BYTES_PER_SECOND
+//
+//	LOCAL_STORAGE_DIR, LOCAL_MAX_BYTES_PER_SECOND
+//
+// Each proxy also reads <BACKEND>_PROXY_NO_PROXY, a comma separated list of hosts to reach directly.
+// S3, GCP, AZURE and ALIOSS also read the tls settings <BACKEND>_TLS_CA_FILE, <BACKEND>_TLS_CA, <BACKEND>_TLS_CERT_FILE,
+// <BACKEND>_TLS_KEY_FILE, <BACKEND>_TLS_MIN_VERSION and <BACKEND>_TLS_INSECURE_SKIP_VERIFY, and the transport settings
+// <BACKEND>_TRANSPORT_ followed by DIAL_TIMEOUT, KEEP_ALIVE, TLS_HANDSHAKE_TIMEOUT, RESPONSE_HEADER_TIMEOUT,
+// IDLE_CONN_TIMEOUT, MAX_IDLE_CONNS, MAX_IDLE_CONNS_PER_HOST, MAX_CONNS_PER_HOST, DISABLE_KEEP_ALIVES,
+// DISABLE_HTTP2 or USER_AGENT. The retry policy is read from <BACKEND>_RETRY_ followed by MAX_ATTEMPTS, MIN_DELAY,
+// MAX_DELAY, DISABLE_JITTER, RETRYABLE_STATUS_CODES (comma separated) or ATTEMPT_TIMEOUT, and the operation timeouts
+// from <BACKEND>_TIMEOUT_ followed by CONNECT, METADATA, LIST, UPLOAD, DOWNLOAD or PRESIGN.
+// Durations use time.ParseDuration syntax, and metadata is a comma separated list of key=value pairs.
+// The unprefixed AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and GOOGLE_APPLICATION_CREDENTIALS (a file path)
+// are used when the corresponding variables are not set.
+
+// LoadEnv creates the config of each backend with at least one variable set, see above.
+// The well-known unprefixed variables alone do not select a backend.
+func (c *StorageConfig) LoadEnv(prefix string) error {
+	var errs []error
+	loadBackendEnv(prefix, &c.S3, (*S3Config).loadEnv, &errs)
+	loadBackendEnv(prefix, &c.GCP, (*GCPConfig).loadEnv, &errs)
+	loadBackendEnv(prefix, &c.Azure, (*AzureConfig).loadEnv, &errs)
+	loadBackendEnv(prefix, &c.AliOSS, (*AliOSSConfig).loadEnv, &errs)
+	loadBackendEnv(prefix, &c.Local, (*LocalConfig).loadEnv, &errs)
+	return errors.Join(errs...)
+}
+
+// loadBackendEnv loads into an existing config, or creates it if any of its variables are set
+func loadBackendEnv[T any](prefix string, conf **T, loader func(*T, *envLoader), errs *[]error) {
+	c := *conf
+	if c == nil {
+		c = new(T)
+	}
+
+	e := &envLoader{prefix: prefix}
+	loader(c, e)
+	if e.found || *conf != nil {
+		*conf = c
+		*errs = append(*errs, e.errs...)
+	}
+}
+
+func (c *S3Config) LoadEnv(prefix string) error {
+	return loadEnv(prefix, c.loadEnv)
+}
+
+func (c *S3Config) loadEnv(e *envLoader) {
+	e.string("S3_ACCESS_KEY", &c.AccessKey)
+	e.string("S3_SECRET", &c.Secret)
+	e.string("S3_SESSION_TOKEN", &c.SessionToken)
+	e.string("S3_ASSUME_ROLE_ARN", &c.AssumeRoleArn)
+	e.string("S3_ASSUME_ROLE_EXTERNAL_ID", &c.AssumeRoleExternalId)
+	e.string("S3_REGION", &c.Region)
+	e.string("S3_ENDPOINT", &c.Endpoint)
+	e.string("S3_BUCKET", &c.Bucket)
+	e.bool("S3_FORCE_PATH_STYLE", &c.ForcePathStyle)
+	e.proxy("S3_PROXY", &c.ProxyConfig)
+	e.tls("S3_TLS", &c.TLSConfig)
+	e.transport("S3_TRANSPORT", &c.TransportConfig)
+	e.retry("S3_RETRY", &c.RetryConfig)
+	e.timeout("S3_TIMEOUT", &c.TimeoutConfig)
+	e.int("S3_MAX_RETRIES", &c.MaxRetries)
+	e.duration("S3_MAX_RETRY_DELAY", &c.MaxRetryDelay)
+	e.duration("S3_MIN_RETRY_DELAY", &c.MinRetryDelay)
+	e.metadata("S3_METADATA", &c.Metadata)
+	e.string("S3_TAGGING", &c.Tagging)
+	e.string("S3_CONTENT_DISPOSITION", &c.ContentDisposition)
+	e.int("S3_MAX_BYTES_PER_SECOND", &c.MaxBytesPerSecond)
+}
+
+func (c *GCPConfig) LoadEnv(prefix string) error {
+	return loadEnv(prefix, c.loadEnv)
+}
+
+func (c *GCPConfig) loadEnv(e *envLoader) {
+	e.string("GCP_CREDENTIALS_JSON", &c.CredentialsJSON)
+	e.string("GCP_BUCKET", &c.Bucket)
+	e.string("GCP_PROJECT_ID", &c.ProjectID)
+	e.proxy("GCP_PROXY", &c.ProxyConfig)
+	e.tls("GCP_TLS", &c.TLSConfig)
+	e.transport("GCP_TRANSPORT", &c.TransportConfig)
+	e.retry("GCP_RETRY", &c.RetryConfig)
+	e.timeout("GCP_TIMEOUT", &c.TimeoutConfig)
+	e.int("GCP_MAX_BYTES_PER_SECOND", &c.MaxBytesPerSecond)
+
+	if c.CredentialsJSON == "" {
+		// a file reference, so that rotated credentials are picked up
+		if filename := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); filename != "" {
+			c.CredentialsJSON = secretFilePrefix + filename
+		}
+	}
+}
+
+func (c *AzureConfig) LoadEnv(prefix string) error {
+	return loadEnv(prefix, c.loadEnv)
+}
+
+func (c *AzureConfig) loadEnv(e *envLoader) {
+	e.string("AZURE_ACCOUNT_NAME", &c.AccountName)
+	e.string("AZURE_ACCOUNT_KEY", &c.AccountKey)
+	e.string("AZURE_CONTAINER_NAME", &c.ContainerName)
+	e.proxy("AZURE_PROXY", &c.ProxyConfig)
+	e.tls("AZURE_TLS", &c.TLSConfig)
+	e.transport("AZURE_TRANSPORT", &c.TransportConfig)
+	e.retry("AZURE_RETRY", &c.RetryConfig)
+	e.timeout("AZURE_TIMEOUT", &c.TimeoutConfig)
+	e.int("AZURE_MAX_BYTES_PER_SECOND", &c.MaxBytesPerSecond)
+
+	if c.AccountName == "" {
+		c.AccountName = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	if c.AccountKey == "" {
+		c.AccountKey = os.Getenv("AZURE_STORAGE_KEY")
+	}
+}
+
+func (c *AliOSSConfig) LoadEnv(prefix string) error {
+	return loadEnv(prefix, c.loadEnv)
+}
+
+func (c *AliOSSConfig) loadEnv(e *envLoader) {
+	e.string("ALIOSS_ACCESS_KEY", &c.AccessKey)
+	e.string("ALIOSS_SECRET", &c.Secret)
+	e.string("ALIOSS_ENDPOINT", &c.Endpoint)
+	e.string("ALIOSS_BUCKET", &c.Bucket)
+	e.proxy("ALIOSS_PROXY", &c.ProxyConfig)
+	e.tls("ALIOSS_TLS", &c.TLSConfig)
+	e.transport("ALIOSS_TRANSPORT", &c.TransportConfig)
+	e.retry("ALIOSS_RETRY", &c.RetryConfig)
+	e.timeout("ALIOSS_TIMEOUT", &c.TimeoutConfig)
+	e.int("ALIOSS_MAX_BYTES_PER_SECOND", &c.MaxBytesPerSecond)
+}
+
+func (c *LocalConfig) LoadEnv(prefix string) error {
+	return loadEnv(prefix, c.loadEnv)
+}
+
+func (c *LocalConfig) loadEnv(e *envLoader) {
+	e.string("LOCAL_STORAGE_DIR", &c.StorageDir)
+	e.int("LOCAL_MAX_BYTES_PER_SECOND", &c.MaxBytesPerSecond)
+}
+
+func loadEnv(prefix string, loader func(e *envLoader)) error {
+	e := &envLoader{prefix: prefix}
+	loader(e)
+	return errors.Join(e.errs...)
+}
+
+// envLoader reads prefixed variables, recording whether any was set and collecting parse errors
+type envLoader struct {
+	prefix string
+	found  bool
+	errs   []error
+}
+
+func (e *envLoader) lookup(name string) (string, bool) {
+	value, ok := os.LookupEnv(e.prefix + name)
+	if ok {
+		e.found = true
+	}
+	return value, ok
+}
+
+func (e *envLoader) fail(name string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s%s: %w", e.prefix, name, err))
+}
+
+func (e *envLoader) string(name string, dst *string) {
+	if value, ok := e.lookup(name); ok {
+		*dst = value
+	}
+}
+
+func (e *envLoader) bool(name string, dst *bool) {
+	if value, ok := e.lookup(name); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envLoader) int(name string, dst *int) {
+	if value, ok := e.lookup(name); ok {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*dst = i
+	}
+}
+
+func (e *envLoader) duration(name string, dst *time.Duration) {
+	if value, ok := e.lookup(name); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e *envLoader) metadata(name string, dst *map[string]string) {
+	if value, ok := e.lookup(name); ok {
+		metadata := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				e.fail(name, fmt.Errorf("expected key=value, got %q", pair))
+				return
+			}
+			metadata[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		*dst = metadata
+	}
+}
+
+type envField struct {
+	suffix string
+	dst    *string
+}
+
+func (e *envLoader) proxy(name string, dst **ProxyConfig) {
+	conf := *dst
+	if conf == nil {
+		conf = &ProxyConfig{}
+	}
+
+	found := false
+	for _, field := range []envField{
+		{"_URL", &conf.Url},
+		{"_USERNAME", &conf.Username},
+		{"_PASSWORD", &conf.Password},
+	} {
+		if value, ok := e.lookup(name + field.suffix); ok {
+			*field.dst = value
+			found = true
+		}
+	}
+	if value, ok := e.lookup(name + "_NO_PROXY"); ok {
+		conf.NoProxy = splitList(value)
+		found = true
+	}
+	if found {
+		*dst = conf
+	}
+}
+
+func (e *envLoader) tls(name string, dst **TLSConfig) {
+	conf := *dst
+	if conf == nil {
+		conf = &TLSConfig{}
+	}
+
+	found := false
+	for _, field := range []envField{
+		{"_CA_FILE", &conf.CAFile},
+		{"_CA", &conf.CA},
+		{"_CERT_FILE", &conf.CertFile},
+		{"_KEY_FILE", &conf.KeyFile},
+		{"_MIN_VERSION", &conf.MinVersion},
+	} {
+		if value, ok := e.lookup(name + field.suffix); ok {
+			*field.dst = value
+			found = true
+		}
+	}
+	if _, ok := e.lookup(name + "_INSECURE_SKIP_VERIFY"); ok {
+		e.bool(name+"_INSECURE_SKIP_VERIFY", &conf.InsecureSkipVerify)
+		found = true
+	}
+	if found {
+		*dst = conf
+	}
+}
+
+func (e *envLoader) transport(name string, dst **TransportConfig) {
+	conf := *dst
+	if conf == nil {
+		conf = &TransportConfig{}
+	}
+
+	// a separate loader tells whether any of the transport variables is set
+	t := &envLoader{prefix: e.prefix + name + "_"}
+	t.duration("DIAL_TIMEOUT", &conf.DialTimeout)
+	t.duration("KEEP_ALIVE", &conf.KeepAlive)
+	t.duration("TLS_HANDSHAKE_TIMEOUT", &conf.TLSHandshakeTimeout)
+	t.duration("RESPONSE_HEADER_TIMEOUT", &conf.ResponseHeaderTimeout)
+	t.duration("IDLE_CONN_TIMEOUT", &conf.IdleConnTimeout)
+	t.int("MAX_IDLE_CONNS", &conf.MaxIdleConns)
+	t.int("MAX_IDLE_CONNS_PER_HOST", &conf.MaxIdleConnsPerHost)
+	t.int("MAX_CONNS_PER_HOST", &conf.MaxConnsPerHost)
+	t.bool("DISABLE_KEEP_ALIVES", &conf.DisableKeepAlives)
+	t.bool("DISABLE_HTTP2", &conf.DisableHTTP2)
+	t.string("USER_AGENT", &conf.UserAgent)
+
+	e.errs = append(e.errs, t.errs...)
+	if t.found {
+		e.found = true
+		*dst = conf
+	}
+}
+
+func (e *envLoader) retry(name string, dst **RetryConfig) {
+	conf := *dst
+	if conf == nil {
+		conf = &RetryConfig{}
+	}
+
+	t := &envLoader{prefix: e.prefix + name + "_"}
+	t.int("MAX_ATTEMPTS", &conf.MaxAttempts)
+	t.duration("MIN_DELAY", &conf.MinDelay)
+	t.duration("MAX_DELAY", &conf.MaxDelay)
+	t.bool("DISABLE_JITTER", &conf.DisableJitter)
+	if value, ok := t.lookup("RETRYABLE_STATUS_CODES"); ok {
+		var codes []int
+		for _, item := range splitList(value) {
+			code, err := strconv.Atoi(item)
+			if err != nil {
+				t.fail("RETRYABLE_STATUS_CODES", err)
+				codes = nil
+				break
+			}
+			codes = append(codes, code)
+		}
+		if codes != nil {
+			conf.RetryableStatusCodes = codes
+		}
+	}
+	t.duration("ATTEMPT_TIMEOUT", &conf.AttemptTimeout)
+
+	e.errs = append(e.errs, t.errs...)
+	if t.found {
+		e.found = true
+		*dst = conf
+	}
+}
+
+func (e *envLoader) timeout(name string, dst **TimeoutConfig) {
+	conf := *dst
+	if conf == nil {
+		conf = &TimeoutConfig{}
+	}
+
+	t := &envLoader{prefix: e.prefix + name + "_"}
+	t.duration("CONNECT", &conf.Connect)
+	t.duration("METADATA", &conf.Metadata)
+	t.duration("LIST", &conf.List)
+	t.duration("UPLOAD", &conf.Upload)
+	t.duration("DOWNLOAD", &conf.Download)
+	t.duration("PRESIGN", &conf.Presign)
+
+	e.errs = append(e.errs, t.errs...)
+	if t.found {
+		e.found = true
+		*dst = conf
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
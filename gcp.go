@@ -17,98 +17,194 @@ package storage
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/googleapis/gax-go/v2"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 const storageScope = "https://www.googleapis.com/auth/devstorage.read_write"
 
+// gcpDefaultScopes are requested for the default credentials, as the storage client does by default
+var gcpDefaultScopes = []string{storage.ScopeFullControl, "https://www.googleapis.com/auth/cloud-platform"}
+
+const (
+	gcpUploadUrl           = "https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s"
+	gcpSessionChunk        = 16 * 1024 * 1024 // must be a multiple of 256 KiB
+	statusResumeIncomplete = 308
+)
+
 type gcpStorage struct {
-	conf   *GCPConfig
-	client *storage.Client
+	conf        *GCPConfig
+	client      *storage.Client
+	tokenSource *gcpTokenSource // nil when using the default credentials
+	tokenClient *http.Client    // unauthorized client, for token requests
+	httpClient  *http.Client    // authorized client, shared by the storage client and resumable upload sessions
+	limiter     *RateLimiter
 }
 
 func NewGCP(conf *GCPConfig) (Storage, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
 	u := &gcpStorage{
-		conf: conf,
+		conf:    conf,
+		limiter: newConfigRateLimiter(conf.MaxBytesPerSecond, conf.RateLimiter),
 	}
 
-	var opts []option.ClientOption
-	if conf.CredentialsJSON != "" {
-		jwtConfig, err := google.JWTConfigFromJSON([]byte(conf.CredentialsJSON), storageScope)
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, option.WithTokenSource(jwtConfig.TokenSource(context.Background())))
+	provider := conf.CredentialsProvider
+	if provider == nil && conf.CredentialsJSON != "" {
+		provider = &secretCredentials{credentialsJSON: newSecret(conf.CredentialsJSON)}
 	}
 
-	defaultTransport := http.DefaultTransport.(*http.Transport)
-	transportClone := defaultTransport.Clone()
+	// token requests use the same client, without authorization
+	tokenClient, err := newHTTPClient(conf.HTTPClient, conf.ProxyConfig, conf.TLSConfig, conf.TransportConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	if conf.ProxyConfig != nil {
-		proxyUrl, err := url.Parse(conf.ProxyConfig.Url)
-		if err != nil {
+	var opts []option.ClientOption
+	var ts oauth2.TokenSource
+	if provider != nil {
+		u.tokenSource = &gcpTokenSource{provider: provider, httpClient: tokenClient}
+		if _, err := u.tokenSource.get(); err != nil {
 			return nil, err
 		}
-		defaultTransport.Proxy = http.ProxyURL(proxyUrl)
-		if conf.ProxyConfig.Username != "" && conf.ProxyConfig.Password != "" {
-			auth := fmt.Sprintf("%s:%s", conf.ProxyConfig.Username, conf.ProxyConfig.Password)
-			basicAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-			defaultTransport.ProxyConnectHeader = http.Header{}
-			defaultTransport.ProxyConnectHeader.Add("Proxy-Authorization", basicAuth)
+		ts = u.tokenSource
+		// not used for requests, which go through the http client, but lets the client detect signing credentials
+		opts = append(opts, option.WithTokenSource(ts))
+	} else {
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tokenClient)
+		if ts, err = google.DefaultTokenSource(ctx, gcpDefaultScopes...); err != nil {
+			return nil, err
 		}
 	}
-	client, err := storage.NewClient(context.Background(), opts...)
 
-	// restore default transport
-	http.DefaultTransport = transportClone
+	u.tokenClient = tokenClient
+	u.httpClient = &http.Client{
+		Transport:     &oauth2.Transport{Source: ts, Base: tokenClient.Transport},
+		CheckRedirect: tokenClient.CheckRedirect,
+		Jar:           tokenClient.Jar,
+		Timeout:       tokenClient.Timeout,
+	}
+	policy := newRetryPolicy(conf.RetryConfig, retryPolicy{
+		maxAttempts: 5,
+		minDelay:    100 * time.Millisecond,
+		maxDelay:    5 * time.Second,
+	})
+	u.httpClient = policy.withAttemptTimeout(u.httpClient)
+	opts = append(opts, option.WithHTTPClient(u.httpClient))
+
+	client, err := storage.NewClient(context.Background(), opts...)
 	if err != nil {
+		u.closeIdleConnections()
 		return nil, err
 	}
+	// uploads and downloads also retry non-idempotent requests, see upload and download.
+	// The sdk always randomizes the backoff.
+	client.SetRetry(
+		storage.WithBackoff(gax.Backoff{
+			Initial:    policy.minDelay,
+			Max:        policy.maxDelay,
+			Multiplier: 2,
+		}),
+		storage.WithMaxAttempts(policy.maxAttempts),
+		storage.WithErrorFunc(func(err error) bool {
+			var apiErr *googleapi.Error
+			return storage.ShouldRetry(err) || (errors.As(err, &apiErr) && policy.retryableStatus(apiErr.Code))
+		}),
+	)
 
 	u.client = client
 	return u, nil
 }
 
-func (s *gcpStorage) UploadData(data []byte, storagePath, contentType string) (string, int64, error) {
-	return s.upload(bytes.NewReader(data), storagePath, contentType)
+func (s *gcpStorage) Capabilities() Capabilities {
+	return Capabilities{
+		PresignedURLs:  true,
+		ServerSideCopy: true,
+		Versioning:     true,
+		Lifecycle:      true,
+		CORS:           true,
+	}
 }
 
-func (s *gcpStorage) UploadFile(filepath, storagePath, contentType string) (string, int64, error) {
+func (s *gcpStorage) UploadData(data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.uploadData(context.Background(), data, storagePath, contentType, opts...)
+}
+
+func (s *gcpStorage) uploadData(ctx context.Context, data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.upload(ctx, bytes.NewReader(data), int64(len(data)), storagePath, contentType, nil, opts)
+}
+
+func (s *gcpStorage) UploadFile(filepath, storagePath, contentType string, opts ...Option) (string, int64, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return "", 0, err
 	}
 	defer file.Close()
 
-	return s.upload(file, storagePath, contentType)
+	stat, err := file.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+
+	return s.upload(context.Background(), file, stat.Size(), storagePath, contentType, nil, opts)
 }
 
-func (s *gcpStorage) upload(reader io.Reader, storagePath, _ string) (string, int64, error) {
-	wc := s.client.Bucket(s.conf.Bucket).Object(storagePath).Retryer(
-		storage.WithBackoff(gax.Backoff{
-			Initial:    time.Millisecond * 100,
-			Max:        time.Second * 5,
-			Multiplier: 2,
-		}),
-		storage.WithMaxAttempts(5),
+// upload bounds the whole transfer by the upload timeout, which cancels the writer
+func (s *gcpStorage) upload(ctx context.Context, reader io.ReadSeeker, size int64, storagePath, contentType string, conds *storage.Conditions, opts []Option) (_ string, _ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	o := getOptions(ctx, s.limiter, opts)
+
+	contentType, err = resolveContentType(contentType, storagePath, reader)
+	if err != nil {
+		return "", 0, err
+	}
+	checksums, err := o.uploadChecksums(reader)
+	if err != nil {
+		return "", 0, err
+	}
+
+	obj := s.client.Bucket(s.conf.Bucket).Object(storagePath)
+	if conds != nil {
+		obj = obj.If(*conds)
+	}
+	wc := obj.Retryer(
 		storage.WithPolicy(storage.RetryAlways),
-	).NewWriter(context.Background())
+	).NewWriter(ctx)
 	wc.ChunkRetryDeadline = 0
+	wc.ContentType = contentType
+	if checksums != nil {
+		wc.CRC32C = binary.BigEndian.Uint32(checksums.CRC32C)
+		wc.SendCRC32C = true
+		wc.MD5 = checksums.MD5
+	}
+	if o.progress != nil {
+		wc.ProgressFunc = func(n int64) {
+			o.progress(n, size)
+		}
+	}
 
-	n, err := io.Copy(wc, reader)
+	n, err := io.Copy(wc, newThrottledReader(o.ctx, reader, o.limiters))
 	if err != nil {
 		return "", 0, err
 	}
@@ -120,8 +216,207 @@ func (s *gcpStorage) upload(reader io.Reader, storagePath, _ string) (string, in
 	return fmt.Sprintf("https://%s.storage.googleapis.com/%s", s.conf.Bucket, storagePath), n, nil
 }
 
+func (s *gcpStorage) UploadFileResumable(j *Journal, filepath, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
+	state, file, err := j.prepare(backendGCP, s.conf.Bucket, filepath, storagePath, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	o := getOptions(ctx, s.limiter, opts)
+
+	// the session keeps track of the uploaded bytes, ask where to continue from
+	var offset int64
+	done := false
+	if state.SessionURI != "" {
+		if done, offset, err = gcpPutChunk(ctx, s.httpClient, state.SessionURI, nil, -1, state.Size); err != nil {
+			return "", 0, err
+		}
+	} else {
+		if state.SessionURI, err = s.startSession(ctx, storagePath, state.ContentType, state.Size); err != nil {
+			return "", 0, err
+		}
+		state.PartSize = gcpSessionChunk
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+	}
+
+	for !done {
+		buf, err := readPart(file, offset, min(state.PartSize, state.Size-offset), o)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if done, offset, err = gcpPutChunk(ctx, s.httpClient, state.SessionURI, buf, offset, state.Size); err != nil {
+			return "", 0, err
+		}
+		if o.progress != nil {
+			o.progress(offset, state.Size)
+		}
+	}
+
+	if err = j.Remove(state); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("https://%s.storage.googleapis.com/%s", s.conf.Bucket, storagePath), state.Size, nil
+}
+
+func (s *gcpStorage) ResumeUpload(j *Journal, state *UploadState, opts ...Option) (string, int64, error) {
+	return resumeUpload(s, backendGCP, s.conf.Bucket, j, state, opts)
+}
+
+// gcpTokenSource consults a CredentialsProvider on each request,
+// rebuilding the jwt token source when the service account key changes
+type gcpTokenSource struct {
+	provider   CredentialsProvider
+	httpClient *http.Client // for token requests
+
+	mu              sync.Mutex
+	credentialsJSON string
+	ts              oauth2.TokenSource
+}
+
+func (t *gcpTokenSource) Token() (*oauth2.Token, error) {
+	ts, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+	return ts.Token()
+}
+
+func (t *gcpTokenSource) get() (oauth2.TokenSource, error) {
+	creds, err := t.provider.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if creds.CredentialsJSON == "" {
+		if creds.Token == "" {
+			return nil, errors.New("gcp: credentials provider returned neither credentials json nor a token")
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: creds.Token,
+			TokenType:   "Bearer",
+			Expiry:      creds.Expires,
+		}), nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ts == nil || creds.CredentialsJSON != t.credentialsJSON {
+		jwtConfig, err := google.JWTConfigFromJSON([]byte(creds.CredentialsJSON), storageScope)
+		if err != nil {
+			return nil, err
+		}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, t.httpClient)
+		t.ts = oauth2.ReuseTokenSource(nil, jwtConfig.TokenSource(ctx))
+		t.credentialsJSON = creds.CredentialsJSON
+	}
+	return t.ts, nil
+}
+
+// projectID returns the project of the current service account key, if any
+func (t *gcpTokenSource) projectID() (string, error) {
+	creds, err := t.provider.Credentials(context.Background())
+	if err != nil || creds.CredentialsJSON == "" {
+		return "", err
+	}
+
+	var key struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err = json.Unmarshal([]byte(creds.CredentialsJSON), &key); err != nil {
+		return "", err
+	}
+	return key.ProjectID, nil
+}
+
+// startSession initiates a resumable upload and returns the session uri
+func (s *gcpStorage) startSession(ctx context.Context, storagePath, contentType string, size int64) (string, error) {
+	body, err := json.Marshal(map[string]string{"contentType": contentType})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(gcpUploadUrl, s.conf.Bucket, url.QueryEscape(storagePath)), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	if contentType != "" {
+		req.Header.Set("X-Upload-Content-Type", contentType)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", gcpSessionError(resp)
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+// gcpPutChunk uploads a chunk to the session, or queries its status if offset is negative.
+// It returns whether the upload is complete and the offset to continue from.
+func gcpPutChunk(ctx context.Context, client *http.Client, sessionURI string, buf []byte, offset, size int64) (bool, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURI, bytes.NewReader(buf))
+	if err != nil {
+		return false, 0, err
+	}
+	switch {
+	case offset < 0 || size == 0:
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	default:
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(buf))-1, size))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return true, size, nil
+	case statusResumeIncomplete:
+		// Range is bytes=0-N, and missing when nothing was persisted yet
+		next := int64(0)
+		if r := resp.Header.Get("Range"); r != "" {
+			if _, err = fmt.Sscanf(r, "bytes=0-%d", &next); err != nil {
+				return false, 0, err
+			}
+			next++
+		}
+		return false, next, nil
+	default:
+		return false, 0, gcpSessionError(resp)
+	}
+}
+
+func gcpSessionError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("resumable upload failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
 func (s *gcpStorage) ListObjects(prefix string) ([]string, error) {
-	it := s.client.Bucket(s.conf.Bucket).Objects(context.Background(), &storage.Query{
+	return s.listObjects(context.Background(), prefix)
+}
+
+func (s *gcpStorage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutList)
+	defer cancel()
+
+	it := s.client.Bucket(s.conf.Bucket).Objects(ctx, &storage.Query{
 		Prefix: prefix,
 	})
 
@@ -132,90 +427,493 @@ func (s *gcpStorage) ListObjects(prefix string) ([]string, error) {
 			if errors.Is(err, iterator.Done) {
 				return objects, nil
 			}
-			return nil, err
+			return nil, timeoutError(ctx, err)
 		}
 		objects = append(objects, attr.Name)
 	}
 }
 
-func (s *gcpStorage) DownloadData(storagePath string) ([]byte, error) {
-	rc, err := s.download(storagePath)
+func (s *gcpStorage) DownloadData(storagePath string, opts ...Option) ([]byte, error) {
+	return s.downloadData(context.Background(), storagePath, opts...)
+}
+
+func (s *gcpStorage) downloadData(ctx context.Context, storagePath string, opts ...Option) ([]byte, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutDownload)
+	defer cancel()
+
+	rc, err := s.download(ctx, storagePath)
 	if err != nil {
-		return nil, err
+		return nil, timeoutError(ctx, err)
 	}
+	defer rc.Close()
 
+	o := getOptions(ctx, s.limiter, opts)
 	b := make([]byte, rc.Attrs.Size)
-	_, err = rc.Read(b)
-	if err != nil {
+	if _, err = io.ReadFull(o.reader(rc, rc.Attrs.Size), b); err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	if err = o.verifyChecksums(bytes.NewReader(b), gcpChecksums(rc)); err != nil {
 		return nil, err
 	}
 	return b, nil
 }
 
-func (s *gcpStorage) DownloadFile(filepath, storagePath string) (int64, error) {
+func (s *gcpStorage) DownloadFile(filepath, storagePath string, opts ...Option) (int64, error) {
 	file, err := os.Create(filepath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
-	rc, err := s.download(storagePath)
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutDownload)
+	defer cancel()
+
+	rc, err := s.download(ctx, storagePath)
 	if err != nil {
-		return 0, err
+		return 0, timeoutError(ctx, err)
 	}
 
-	_, err = io.Copy(file, rc)
+	o := getOptions(ctx, s.limiter, opts)
+	_, err = io.Copy(file, o.reader(rc, rc.Attrs.Size))
 	_ = rc.Close()
 	if err != nil {
+		return 0, timeoutError(ctx, err)
+	}
+	if err = o.verifyFile(filepath, gcpChecksums(rc)); err != nil {
 		return 0, err
 	}
 
 	return rc.Attrs.Size, nil
 }
 
-func (s *gcpStorage) download(storagePath string) (*storage.Reader, error) {
-	ctx := context.Background()
-	var client *storage.Client
+// gcpChecksums returns the checksums of the object being read, unknown if it was decompressed
+func gcpChecksums(rc *storage.Reader) *Checksums {
+	if rc.Attrs.Decompressed {
+		return nil
+	}
+	return &Checksums{CRC32C: crc32cBytes(rc.Attrs.CRC32C)}
+}
 
-	var err error
-	if s.conf.CredentialsJSON != "" {
-		client, err = storage.NewClient(ctx, option.WithCredentialsJSON([]byte(s.conf.CredentialsJSON)))
-	} else {
-		client, err = storage.NewClient(ctx)
+// download returns a reader bound to ctx, which must stay open until the data is read
+func (s *gcpStorage) download(ctx context.Context, storagePath string) (*storage.Reader, error) {
+	return s.client.Bucket(s.conf.Bucket).Object(storagePath).Retryer(
+		storage.WithPolicy(storage.RetryAlways),
+	).NewReader(ctx)
+}
+
+// Append uploads the data to a temporary object and composes it onto the original.
+// GCP limits composite objects to 1024 components, which caps the number of appends per object.
+func (s *gcpStorage) Append(storagePath string, data []byte) (_ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	bucket := s.client.Bucket(s.conf.Bucket)
+	obj := bucket.Object(storagePath)
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		if !errors.Is(err, storage.ErrObjectNotExist) {
+			return 0, err
+		}
+		// only create the object if no other append did meanwhile
+		_, size, err := s.upload(ctx, bytes.NewReader(data), int64(len(data)), storagePath, "", &storage.Conditions{DoesNotExist: true}, nil)
+		return size, gcpAppendError(err)
+	}
+	if len(data) == 0 {
+		return attrs.Size, nil
+	}
+
+	tmpPath := fmt.Sprintf("%s.append-%d", storagePath, time.Now().UnixNano())
+	if _, _, err = s.UploadData(data, tmpPath, attrs.ContentType); err != nil {
+		return 0, err
 	}
+	tmp := bucket.Object(tmpPath)
+	defer func() {
+		_ = tmp.Delete(context.Background())
+	}()
+
+	// fail if the object is modified concurrently
+	composer := obj.If(storage.Conditions{GenerationMatch: attrs.Generation}).ComposerFrom(obj, tmp)
+	composer.ContentType = attrs.ContentType
+	composer.ContentDisposition = attrs.ContentDisposition
+	composer.Metadata = attrs.Metadata
+
+	composed, err := composer.Run(ctx)
 	if err != nil {
-		return nil, err
+		return 0, gcpAppendError(err)
 	}
-	defer client.Close()
 
-	return client.Bucket(s.conf.Bucket).Object(storagePath).Retryer(
-		storage.WithBackoff(
-			gax.Backoff{
-				Initial:    time.Millisecond * 100,
-				Max:        time.Second * 5,
-				Multiplier: 2,
-			}),
-		storage.WithPolicy(storage.RetryAlways),
-	).NewReader(ctx)
+	return composed.Size, nil
 }
 
+// gcpAppendError reports failed preconditions as ErrAppendConflict
+func gcpAppendError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %w", ErrAppendConflict, err)
+	}
+	return err
+}
+
+// gcpMaxComposeSources is the maximum number of sources of a single compose request
+const gcpMaxComposeSources = 32
+
+// ConcatObjects composes the sources into dst. Beyond 32 sources, they are first composed in groups
+// into temporary objects, which are deleted once done.
+func (s *gcpStorage) ConcatObjects(dst string, srcs ...string) (err error) {
+	if len(srcs) == 0 {
+		return ErrNoSources
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	bucket := s.client.Bucket(s.conf.Bucket)
+	attrs, err := bucket.Object(srcs[0]).Attrs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tmps []*storage.ObjectHandle
+	defer func() {
+		for _, tmp := range tmps {
+			_ = tmp.Delete(context.Background())
+		}
+	}()
+
+	objects := make([]*storage.ObjectHandle, len(srcs))
+	for i, src := range srcs {
+		objects[i] = bucket.Object(src)
+	}
+
+	prefix := fmt.Sprintf("%s.concat-%d", dst, time.Now().UnixNano())
+	for level := 0; len(objects) > gcpMaxComposeSources; level++ {
+		next := make([]*storage.ObjectHandle, 0, (len(objects)+gcpMaxComposeSources-1)/gcpMaxComposeSources)
+		for i := 0; i < len(objects); i += gcpMaxComposeSources {
+			tmp := bucket.Object(fmt.Sprintf("%s-%d-%d", prefix, level, len(next)))
+			tmps = append(tmps, tmp)
+			if _, err = tmp.ComposerFrom(objects[i:min(i+gcpMaxComposeSources, len(objects))]...).Run(ctx); err != nil {
+				return err
+			}
+			next = append(next, tmp)
+		}
+		objects = next
+	}
+
+	composer := bucket.Object(dst).ComposerFrom(objects...)
+	composer.ContentType = attrs.ContentType
+	composer.ContentDisposition = attrs.ContentDisposition
+	composer.Metadata = attrs.Metadata
+	_, err = composer.Run(ctx)
+	return err
+}
+
+// GeneratePresignedUrl may query the metadata server or sign with the iam api, which the sdk does without a context,
+// so the result is only awaited until the presign timeout
 func (s *gcpStorage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (string, error) {
-	return s.client.Bucket(s.conf.Bucket).SignedURL(storagePath, &storage.SignedURLOptions{
-		Method:  "GET",
-		Expires: time.Now().Add(expiration),
-	})
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutPresign)
+	defer cancel()
+
+	type signed struct {
+		url string
+		err error
+	}
+	done := make(chan signed, 1)
+	go func() {
+		u, err := s.client.Bucket(s.conf.Bucket).SignedURL(storagePath, &storage.SignedURLOptions{
+			Method:  "GET",
+			Expires: time.Now().Add(expiration),
+		})
+		done <- signed{u, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.url, res.err
+	case <-ctx.Done():
+		return "", timeoutError(ctx, context.Cause(ctx))
+	}
 }
 
 func (s *gcpStorage) DeleteObject(storagePath string) error {
-	return s.client.Bucket(s.conf.Bucket).Object(storagePath).Delete(context.Background())
+	return s.deleteObject(context.Background(), storagePath)
+}
+
+func (s *gcpStorage) deleteObject(ctx context.Context, storagePath string) error {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	return timeoutError(ctx, s.client.Bucket(s.conf.Bucket).Object(storagePath).Delete(ctx))
 }
 
 func (s *gcpStorage) DeleteObjects(storagePaths []string) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
 	bucket := s.client.Bucket(s.conf.Bucket)
 	for _, path := range storagePaths {
-		if err := bucket.Object(path).Delete(context.Background()); err != nil {
+		if err := bucket.Object(path).Delete(ctx); err != nil {
+			return timeoutError(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (s *gcpStorage) BucketExists() (bool, error) {
+	return s.bucketExists(context.Background())
+}
+
+func (s *gcpStorage) bucketExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	_, err := s.client.Bucket(s.conf.Bucket).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return false, nil
+		}
+		return false, timeoutError(ctx, err)
+	}
+
+	return true, nil
+}
+
+func (s *gcpStorage) EnsureBucket(opts *BucketOptions) error {
+	if opts == nil {
+		opts = &BucketOptions{}
+	}
+
+	exists, err := s.BucketExists()
+	if err != nil || exists {
+		return err
+	}
+
+	projectID := s.conf.ProjectID
+	if projectID == "" && s.tokenSource != nil {
+		if projectID, err = s.tokenSource.projectID(); err != nil {
 			return err
 		}
 	}
+	if projectID == "" {
+		return errors.New("project id required to create bucket")
+	}
+
+	// gcp buckets are always encrypted, a kms key only replaces the google-managed key
+	attrs := &storage.BucketAttrs{
+		Location: opts.Region,
+	}
+	if opts.KMSKeyID != "" {
+		attrs.Encryption = &storage.BucketEncryption{
+			DefaultKMSKeyName: opts.KMSKeyID,
+		}
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	err = s.client.Bucket(s.conf.Bucket).Create(ctx, projectID, attrs)
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusConflict {
+		return timeoutError(ctx, err)
+	}
+
+	// the name is global, buckets of other projects cannot be read
+	owned, existsErr := s.BucketExists()
+	if errors.As(existsErr, &apiErr) && apiErr.Code == http.StatusForbidden {
+		owned, existsErr = false, nil
+	}
+	if existsErr != nil {
+		return existsErr
+	}
+	if !owned {
+		return fmt.Errorf("%w: %w", ErrBucketTaken, err)
+	}
 	return nil
 }
+
+func (s *gcpStorage) GetLifecycleRules() ([]LifecycleRule, error) {
+	attrs, err := s.bucketAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// gcp rules have a single action each, so merge them back together by prefix
+	var rules []LifecycleRule
+	merge := func(prefix string, apply func(*LifecycleRule) bool) {
+		for i := range rules {
+			if rules[i].Prefix == prefix && apply(&rules[i]) {
+				return
+			}
+		}
+		rule := LifecycleRule{Prefix: prefix}
+		apply(&rule)
+		rules = append(rules, rule)
+	}
+
+	for _, r := range attrs.Lifecycle.Rules {
+		age := int(r.Condition.AgeInDays)
+
+		var apply func(*LifecycleRule) bool
+		switch r.Action.Type {
+		case storage.DeleteAction:
+			apply = func(rule *LifecycleRule) bool {
+				if rule.ExpireAfterDays != 0 {
+					return false
+				}
+				rule.ExpireAfterDays = age
+				return true
+			}
+		case storage.SetStorageClassAction:
+			apply = func(rule *LifecycleRule) bool {
+				if rule.TransitionAfterDays != 0 {
+					return false
+				}
+				rule.TransitionAfterDays = age
+				rule.TransitionStorageClass = r.Action.StorageClass
+				return true
+			}
+		case storage.AbortIncompleteMPUAction:
+			apply = func(rule *LifecycleRule) bool {
+				if rule.AbortMultipartAfterDays != 0 {
+					return false
+				}
+				rule.AbortMultipartAfterDays = age
+				return true
+			}
+		default:
+			continue
+		}
+
+		if len(r.Condition.MatchesPrefix) == 0 {
+			merge("", apply)
+		}
+		for _, prefix := range r.Condition.MatchesPrefix {
+			merge(prefix, apply)
+		}
+	}
+
+	return rules, nil
+}
+
+func (s *gcpStorage) PutLifecycleRules(rules []LifecycleRule) error {
+	lifecycle := storage.Lifecycle{}
+	for _, rule := range rules {
+		// age is the only condition allowed for aborting uploads, which would then apply to the whole bucket
+		if rule.AbortMultipartAfterDays > 0 && rule.Prefix != "" {
+			return ErrNotSupported
+		}
+
+		var prefixes []string
+		if rule.Prefix != "" {
+			prefixes = []string{rule.Prefix}
+		}
+
+		if rule.ExpireAfterDays > 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action: storage.LifecycleAction{Type: storage.DeleteAction},
+				Condition: storage.LifecycleCondition{
+					AgeInDays:     int64(rule.ExpireAfterDays),
+					MatchesPrefix: prefixes,
+				},
+			})
+		}
+		if rule.TransitionAfterDays > 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action: storage.LifecycleAction{
+					Type:         storage.SetStorageClassAction,
+					StorageClass: rule.TransitionStorageClass,
+				},
+				Condition: storage.LifecycleCondition{
+					AgeInDays:     int64(rule.TransitionAfterDays),
+					MatchesPrefix: prefixes,
+				},
+			})
+		}
+		if rule.AbortMultipartAfterDays > 0 {
+			lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+				Action: storage.LifecycleAction{Type: storage.AbortIncompleteMPUAction},
+				Condition: storage.LifecycleCondition{
+					AgeInDays: int64(rule.AbortMultipartAfterDays),
+				},
+			})
+		}
+	}
+
+	return s.updateBucket(storage.BucketAttrsToUpdate{
+		Lifecycle: &lifecycle,
+	})
+}
+
+func (s *gcpStorage) GetCORSRules() ([]CORSRule, error) {
+	attrs, err := s.bucketAttrs()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]CORSRule, 0, len(attrs.CORS))
+	for _, c := range attrs.CORS {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: c.Origins,
+			AllowedMethods: c.Methods,
+			ExposedHeaders: c.ResponseHeaders,
+			MaxAgeSeconds:  int(c.MaxAge.Seconds()),
+		})
+	}
+
+	return rules, nil
+}
+
+func (s *gcpStorage) SetCORSRules(rules []CORSRule) error {
+	cors := make([]storage.CORS, 0, len(rules))
+	for _, rule := range rules {
+		// request headers cannot be restricted, gcs allows them all
+		if len(rule.AllowedHeaders) > 0 {
+			return ErrNotSupported
+		}
+		cors = append(cors, storage.CORS{
+			Origins:         rule.AllowedOrigins,
+			Methods:         rule.AllowedMethods,
+			ResponseHeaders: rule.ExposedHeaders,
+			MaxAge:          time.Duration(rule.MaxAgeSeconds) * time.Second,
+		})
+	}
+
+	return s.updateBucket(storage.BucketAttrsToUpdate{
+		CORS: cors,
+	})
+}
+
+func (s *gcpStorage) bucketAttrs() (*storage.BucketAttrs, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	attrs, err := s.client.Bucket(s.conf.Bucket).Attrs(ctx)
+	return attrs, timeoutError(ctx, err)
+}
+
+func (s *gcpStorage) updateBucket(update storage.BucketAttrsToUpdate) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	_, err := s.client.Bucket(s.conf.Bucket).Update(ctx, update)
+	return timeoutError(ctx, err)
+}
+
+func (s *gcpStorage) Validate(ctx context.Context) (*ValidationReport, error) {
+	return validate(ctx, s)
+}
+
+func (s *gcpStorage) Close() error {
+	err := s.client.Close()
+	s.closeIdleConnections()
+	return err
+}
+
+func (s *gcpStorage) closeIdleConnections() {
+	// a configured client is left to its owner
+	if s.conf.HTTPClient == nil {
+		s.tokenClient.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "errors"
+
+// ErrLifecycleNotRepresentable is returned by PutLifecycleRules instead of replacing bucket rules
+// that GetLifecycleRules leaves out, such as disabled rules or rules with several transitions
+var ErrLifecycleNotRepresentable = errors.New("bucket has lifecycle rules that cannot be represented")
+
+// LifecycleRule is a provider-agnostic bucket lifecycle rule.
+// Zero values disable the corresponding action.
+type LifecycleRule struct {
+	ID                      string `yaml:"id,omitempty"`     // ignored by GCP, which does not name rules
+	Prefix                  string `yaml:"prefix,omitempty"` // only objects with this key prefix are affected
+	ExpireAfterDays         int    `yaml:"expire_after_days,omitempty"`
+	TransitionAfterDays     int    `yaml:"transition_after_days,omitempty"`
+	TransitionStorageClass  string `yaml:"transition_storage_class,omitempty"`   // provider-specific, e.g. GLACIER, NEARLINE, IA
+	AbortMultipartAfterDays int    `yaml:"abort_multipart_after_days,omitempty"` // GCP only supports it without a prefix
+}
@@ -15,6 +15,8 @@
 package storage
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -26,9 +28,15 @@ import (
 
 type localUploader struct {
 	StorageDir string
+
+	limiter *RateLimiter
 }
 
 func NewLocal(conf *LocalConfig) (Storage, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
 	dir, err := filepath.Abs(conf.StorageDir)
 	if err != nil {
 		return nil, err
@@ -36,10 +44,19 @@ func NewLocal(conf *LocalConfig) (Storage, error) {
 
 	return &localUploader{
 		StorageDir: dir,
+		limiter:    newConfigRateLimiter(conf.MaxBytesPerSecond, conf.RateLimiter),
 	}, nil
 }
 
-func (u *localUploader) UploadFile(localPath, storagePath string, _ string) (string, int64, error) {
+// Capabilities reports no presigned url support, since the returned file:// urls are only valid on this host
+func (u *localUploader) Capabilities() Capabilities {
+	return Capabilities{
+		ServerSideCopy: true,
+		NativeAppend:   true,
+	}
+}
+
+func (u *localUploader) UploadFile(localPath, storagePath string, _ string, opts ...Option) (string, int64, error) {
 	storagePath = path.Join(u.StorageDir, storagePath)
 
 	local, err := os.Open(localPath)
@@ -48,6 +65,17 @@ func (u *localUploader) UploadFile(localPath, storagePath string, _ string) (str
 	}
 	defer local.Close()
 
+	stat, err := local.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+
+	// there is no provider to validate against, only compute the checksums
+	o := getOptions(context.Background(), u.limiter, opts)
+	if _, err = o.uploadChecksums(local); err != nil {
+		return "", 0, err
+	}
+
 	if dir, _ := path.Split(storagePath); dir != "" {
 		if err = os.MkdirAll(dir, 0755); err != nil {
 			return "", 0, err
@@ -60,7 +88,7 @@ func (u *localUploader) UploadFile(localPath, storagePath string, _ string) (str
 	}
 	defer storage.Close()
 
-	size, err := io.Copy(storage, local)
+	size, err := io.Copy(storage, o.reader(local, stat.Size()))
 	if err != nil {
 		return "", 0, err
 	}
@@ -68,9 +96,18 @@ func (u *localUploader) UploadFile(localPath, storagePath string, _ string) (str
 	return storagePath, size, nil
 }
 
-func (u *localUploader) UploadData(data []byte, storagePath, _ string) (string, int64, error) {
+func (u *localUploader) UploadData(data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return u.uploadData(context.Background(), data, storagePath, contentType, opts...)
+}
+
+func (u *localUploader) uploadData(ctx context.Context, data []byte, storagePath, _ string, opts ...Option) (string, int64, error) {
 	storagePath = path.Join(u.StorageDir, storagePath)
 
+	o := getOptions(ctx, u.limiter, opts)
+	if _, err := o.uploadChecksums(bytes.NewReader(data)); err != nil {
+		return "", 0, err
+	}
+
 	if dir, _ := path.Split(storagePath); dir != "" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return "", 0, err
@@ -83,15 +120,83 @@ func (u *localUploader) UploadData(data []byte, storagePath, _ string) (string,
 	}
 	defer storage.Close()
 
-	size, err := storage.Write(data)
+	size, err := io.Copy(storage, o.reader(bytes.NewReader(data), int64(len(data))))
 	if err != nil {
 		return "", 0, err
 	}
 
-	return storagePath, int64(size), nil
+	return storagePath, size, nil
+}
+
+// UploadFileResumable copies the file part by part next to its destination, which it replaces once complete
+func (u *localUploader) UploadFileResumable(j *Journal, localPath, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	state, file, err := j.prepare(backendLocal, u.StorageDir, localPath, storagePath, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	o := getOptions(context.Background(), u.limiter, opts)
+	storagePath = path.Join(u.StorageDir, storagePath)
+	if state.Checkpoint == "" {
+		if dir, _ := path.Split(storagePath); dir != "" {
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				return "", 0, err
+			}
+		}
+		state.Checkpoint = storagePath + ".part"
+		state.PartSize = partSize(state.Size, 1024*1024, 10000)
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+	}
+
+	partial, err := os.OpenFile(state.Checkpoint, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	defer partial.Close()
+
+	for state.Copied < state.Size {
+		buf, err := readPart(file, state.Copied, min(state.PartSize, state.Size-state.Copied), o)
+		if err != nil {
+			return "", 0, err
+		}
+		if _, err = partial.WriteAt(buf, state.Copied); err != nil {
+			return "", 0, err
+		}
+
+		state.Copied += int64(len(buf))
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+		if o.progress != nil {
+			o.progress(state.Copied, state.Size)
+		}
+	}
+
+	if err = partial.Close(); err != nil {
+		return "", 0, err
+	}
+	if err = os.Rename(state.Checkpoint, storagePath); err != nil {
+		return "", 0, err
+	}
+	if err = j.Remove(state); err != nil {
+		return "", 0, err
+	}
+	return storagePath, state.Size, nil
+}
+
+func (u *localUploader) ResumeUpload(j *Journal, state *UploadState, opts ...Option) (string, int64, error) {
+	return resumeUpload(u, backendLocal, u.StorageDir, j, state, opts)
 }
 
 func (u *localUploader) ListObjects(prefix string) ([]string, error) {
+	return u.listObjects(context.Background(), prefix)
+}
+
+// listObjects, deleteObject and bucketExists only touch the filesystem, which cannot be canceled
+func (u *localUploader) listObjects(_ context.Context, prefix string) ([]string, error) {
 	absPrefix := path.Join(u.StorageDir, prefix)
 	dir, filenamePrefix := path.Split(absPrefix)
 
@@ -127,37 +232,139 @@ func (u *localUploader) ListObjects(prefix string) ([]string, error) {
 	return files, nil
 }
 
-func (u *localUploader) DownloadData(storagePath string) ([]byte, error) {
-	return os.ReadFile(path.Join(u.StorageDir, storagePath))
+func (u *localUploader) DownloadData(storagePath string, opts ...Option) ([]byte, error) {
+	return u.downloadData(context.Background(), storagePath, opts...)
 }
 
-func (u *localUploader) DownloadFile(localPath, storagePath string) (int64, error) {
-	storagePath = path.Join(u.StorageDir, storagePath)
+func (u *localUploader) downloadData(ctx context.Context, storagePath string, opts ...Option) ([]byte, error) {
+	storage, err := os.Open(path.Join(u.StorageDir, storagePath))
+	if err != nil {
+		return nil, err
+	}
+	defer storage.Close()
 
-	local, err := os.Open(localPath)
+	stat, err := storage.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	o := getOptions(ctx, u.limiter, opts)
+	data, err := io.ReadAll(o.reader(storage, stat.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if err = o.verifyChecksums(bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (u *localUploader) DownloadFile(localPath, storagePath string, opts ...Option) (int64, error) {
+	storage, err := os.Open(path.Join(u.StorageDir, storagePath))
+	if err != nil {
+		return 0, err
+	}
+	defer storage.Close()
+
+	stat, err := storage.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if dir, _ := path.Split(localPath); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return 0, err
+		}
+	}
+
+	local, err := os.Create(localPath)
 	if err != nil {
 		return 0, err
 	}
 	defer local.Close()
 
+	o := getOptions(context.Background(), u.limiter, opts)
+	size, err := io.Copy(local, o.reader(storage, stat.Size()))
+	if err != nil {
+		return 0, err
+	}
+	if err = o.verifyFile(localPath, nil); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (u *localUploader) Append(storagePath string, data []byte) (int64, error) {
+	storagePath = path.Join(u.StorageDir, storagePath)
+
 	if dir, _ := path.Split(storagePath); dir != "" {
-		if err = os.MkdirAll(dir, 0755); err != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
 			return 0, err
 		}
 	}
 
-	storage, err := os.Create(storagePath)
+	storage, err := os.OpenFile(storagePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return 0, err
 	}
 	defer storage.Close()
 
-	size, err := io.Copy(local, storage)
+	if _, err = storage.Write(data); err != nil {
+		return 0, err
+	}
+
+	stat, err := storage.Stat()
 	if err != nil {
 		return 0, err
 	}
+	return stat.Size(), nil
+}
 
-	return size, nil
+// ConcatObjects writes the sources to a temporary file, which then replaces dst
+func (u *localUploader) ConcatObjects(dst string, srcs ...string) error {
+	if len(srcs) == 0 {
+		return ErrNoSources
+	}
+
+	dst = path.Join(u.StorageDir, dst)
+	if dir, _ := path.Split(dst); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(path.Dir(dst), ".concat-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, src := range srcs {
+		if err = u.copyInto(tmp, src); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (u *localUploader) copyInto(w io.Writer, storagePath string) error {
+	storage, err := os.Open(path.Join(u.StorageDir, storagePath))
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	_, err = io.Copy(w, storage)
+	return err
 }
 
 func (u *localUploader) GeneratePresignedUrl(storagePath string, _ time.Duration) (string, error) {
@@ -165,6 +372,10 @@ func (u *localUploader) GeneratePresignedUrl(storagePath string, _ time.Duration
 }
 
 func (u *localUploader) DeleteObject(storagePath string) error {
+	return u.deleteObject(context.Background(), storagePath)
+}
+
+func (u *localUploader) deleteObject(_ context.Context, storagePath string) error {
 	storagePath = path.Join(u.StorageDir, storagePath)
 
 	for {
@@ -193,3 +404,48 @@ func (u *localUploader) DeleteObjects(storagePaths []string) error {
 	}
 	return nil
 }
+
+func (u *localUploader) BucketExists() (bool, error) {
+	return u.bucketExists(context.Background())
+}
+
+func (u *localUploader) bucketExists(_ context.Context) (bool, error) {
+	info, err := os.Stat(u.StorageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return info.IsDir(), nil
+}
+
+// EnsureBucket creates the storage directory. Bucket options do not apply to local storage.
+func (u *localUploader) EnsureBucket(_ *BucketOptions) error {
+	return os.MkdirAll(u.StorageDir, 0755)
+}
+
+func (u *localUploader) GetLifecycleRules() ([]LifecycleRule, error) {
+	return nil, ErrNotSupported
+}
+
+func (u *localUploader) PutLifecycleRules(_ []LifecycleRule) error {
+	return ErrNotSupported
+}
+
+func (u *localUploader) GetCORSRules() ([]CORSRule, error) {
+	return nil, ErrNotSupported
+}
+
+func (u *localUploader) SetCORSRules(_ []CORSRule) error {
+	return ErrNotSupported
+}
+
+func (u *localUploader) Validate(ctx context.Context) (*ValidationReport, error) {
+	return validate(ctx, u)
+}
+
+func (u *localUploader) Close() error {
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Option configures a single upload or download
+type Option func(*options)
+
+type options struct {
+	ctx       context.Context // of the operation, ending throttled waits
+	progress  ProgressFunc
+	limiters  []*RateLimiter
+	checksums *Checksums
+}
+
+// getOptions applies opts on top of the storage defaults, for an operation running in ctx
+func getOptions(ctx context.Context, limiter *RateLimiter, opts []Option) *options {
+	o := &options{ctx: ctx}
+	if limiter != nil {
+		o.limiters = append(o.limiters, limiter)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithProgress reports transfer progress to fn
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// WithRateLimit limits this transfer to bytesPerSecond, in addition to any storage-wide limit.
+// A value that is not positive adds no limit.
+func WithRateLimit(bytesPerSecond int) Option {
+	return WithRateLimiter(NewRateLimiter(bytesPerSecond))
+}
+
+// WithRateLimiter limits this transfer with a shared limiter, in addition to any storage-wide limit.
+// A nil limiter adds no limit.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *options) {
+		if limiter != nil {
+			o.limiters = append(o.limiters, limiter)
+		}
+	}
+}
+
+// reader wraps r with the requested throttling and progress reporting
+func (o *options) reader(r io.Reader, total int64) io.Reader {
+	return newProgressReader(newThrottledReader(o.ctx, r, o.limiters), total, o.progress)
+}
+
+// writerAt wraps w with the requested throttling and progress reporting
+func (o *options) writerAt(w io.WriterAt, total int64) io.WriterAt {
+	return newProgressWriterAt(newThrottledWriterAt(o.ctx, w, o.limiters), total, o.progress)
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// ProgressFunc receives the number of bytes transferred so far and the total size, or -1 if unknown.
+// It may be called concurrently by backends that transfer parts in parallel.
+type ProgressFunc func(transferred, total int64)
+
+type progressReader struct {
+	r     io.Reader
+	fn    ProgressFunc
+	total int64
+	n     int64
+}
+
+func newProgressReader(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, fn: fn, total: total}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
+type progressWriterAt struct {
+	w     io.WriterAt
+	fn    ProgressFunc
+	total int64
+	n     atomic.Int64
+}
+
+func newProgressWriterAt(w io.WriterAt, total int64, fn ProgressFunc) io.WriterAt {
+	if fn == nil {
+		return w
+	}
+	return &progressWriterAt{w: w, fn: fn, total: total}
+}
+
+func (p *progressWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	n, err := p.w.WriteAt(b, off)
+	if n > 0 {
+		p.fn(p.n.Add(int64(n)), p.total)
+	}
+	return n, err
+}
+
+type ossProgressListener struct {
+	fn ProgressFunc
+}
+
+func (l *ossProgressListener) ProgressChanged(event *oss.ProgressEvent) {
+	if event.EventType == oss.TransferDataEvent {
+		l.fn(event.ConsumedBytes, event.TotalBytes)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	backendS3     = "s3"
+	backendGCP    = "gcp"
+	backendAzure  = "azure"
+	backendAliOSS = "alioss"
+	backendLocal  = "local"
+)
+
+var ErrUploadStateMismatch = errors.New("upload state does not match this storage or local file")
+
+// UploadState is the journaled progress of a resumable upload
+type UploadState struct {
+	Backend     string    `json:"backend"`
+	Bucket      string    `json:"bucket"`
+	StoragePath string    `json:"storage_path"`
+	LocalPath   string    `json:"local_path"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	PartSize    int64     `json:"part_size,omitempty"`
+
+	UploadID   string         `json:"upload_id,omitempty"`   // s3 multipart upload id
+	Parts      []UploadedPart `json:"parts,omitempty"`       // s3 uploaded parts
+	SessionURI string         `json:"session_uri,omitempty"` // gcp resumable session
+	BlockIDs   []string       `json:"block_ids,omitempty"`   // azure staged blocks
+	Checkpoint string         `json:"checkpoint,omitempty"`  // oss checkpoint file, or local partial copy
+	Copied     int64          `json:"copied,omitempty"`      // local bytes copied
+}
+
+type UploadedPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// Journal persists the state of resumable uploads to a local directory.
+// A journal can be shared by several storages.
+type Journal struct {
+	dir string
+}
+
+func NewJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Journal{dir: dir}, nil
+}
+
+// List returns the state of every interrupted upload
+func (j *Journal) List() ([]*UploadState, error) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*UploadState
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		state, err := j.read(filepath.Join(j.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// Remove discards an upload state. Parts already sent to the provider are not cleaned up,
+// and should be covered by a lifecycle rule.
+func (j *Journal) Remove(state *UploadState) error {
+	if state.Checkpoint != "" {
+		if err := os.Remove(state.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(j.path(state.Backend, state.Bucket, state.StoragePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// prepare opens the local file and returns the journaled state for the upload, or a new one
+func (j *Journal) prepare(backend, bucket, localPath, storagePath, contentType string) (*UploadState, *os.File, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	state, err := j.read(j.path(backend, bucket, storagePath))
+	switch {
+	case os.IsNotExist(err):
+		if contentType, err = resolveContentType(contentType, storagePath, file); err != nil {
+			_ = file.Close()
+			return nil, nil, err
+		}
+		state = &UploadState{
+			Backend:     backend,
+			Bucket:      bucket,
+			StoragePath: storagePath,
+			LocalPath:   localPath,
+			ContentType: contentType,
+			Size:        stat.Size(),
+			ModTime:     stat.ModTime(),
+		}
+	case err != nil:
+		_ = file.Close()
+		return nil, nil, err
+	case state.LocalPath != localPath || state.Size != stat.Size() || !state.ModTime.Equal(stat.ModTime()):
+		_ = file.Close()
+		return nil, nil, ErrUploadStateMismatch
+	}
+
+	return state, file, nil
+}
+
+func (j *Journal) save(state *UploadState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// write and rename, so that a crash never leaves a partial state behind
+	p := j.path(state.Backend, state.Bucket, state.StoragePath)
+	if err = os.WriteFile(p+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+func (j *Journal) read(p string) (*UploadState, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &UploadState{}
+	if err = json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (j *Journal) path(backend, bucket, storagePath string) string {
+	return filepath.Join(j.dir, j.key(backend, bucket, storagePath)+".json")
+}
+
+func (j *Journal) checkpointPath(state *UploadState) string {
+	return filepath.Join(j.dir, j.key(state.Backend, state.Bucket, state.StoragePath)+".cp")
+}
+
+func (j *Journal) key(backend, bucket, storagePath string) string {
+	h := sha256.Sum256([]byte(backend + "/" + bucket + "/" + storagePath))
+	return hex.EncodeToString(h[:])
+}
+
+// resumeUpload continues an upload from its journaled state
+func resumeUpload(s Storage, backend, bucket string, j *Journal, state *UploadState, opts []Option) (string, int64, error) {
+	if state.Backend != backend || state.Bucket != bucket {
+		return "", 0, ErrUploadStateMismatch
+	}
+	return s.UploadFileResumable(j, state.LocalPath, state.StoragePath, state.ContentType, opts...)
+}
+
+// partSize returns the smallest part size of at least minSize that fits the file in maxParts
+func partSize(size, minSize, maxParts int64) int64 {
+	return max(minSize, (size+maxParts-1)/maxParts)
+}
+
+// readPart reads a section of the file into memory, applying the transfer throttling
+func readPart(file *os.File, offset, size int64, o *options) ([]byte, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(newThrottledReader(o.ctx, io.NewSectionReader(file, offset, size), o.limiters), buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retryPolicy is a RetryConfig with the defaults of a backend applied
+type retryPolicy struct {
+	maxAttempts    int
+	minDelay       time.Duration
+	maxDelay       time.Duration
+	jitter         bool
+	statusCodes    map[int]struct{}
+	attemptTimeout time.Duration
+}
+
+// newRetryPolicy applies conf over the backend defaults, adding its status codes to the default ones
+func newRetryPolicy(conf *RetryConfig, defaults retryPolicy) *retryPolicy {
+	p := defaults
+	p.jitter = true
+	p.statusCodes = make(map[int]struct{})
+	for code := range defaults.statusCodes {
+		p.statusCodes[code] = struct{}{}
+	}
+	if conf == nil {
+		return &p
+	}
+
+	if conf.MaxAttempts != 0 {
+		p.maxAttempts = conf.MaxAttempts
+	}
+	if conf.MinDelay != 0 {
+		p.minDelay = conf.MinDelay
+	}
+	if conf.MaxDelay != 0 {
+		p.maxDelay = conf.MaxDelay
+	}
+	if p.maxDelay < p.minDelay {
+		p.maxDelay = p.minDelay
+	}
+	p.jitter = !conf.DisableJitter
+	for _, code := range conf.RetryableStatusCodes {
+		p.statusCodes[code] = struct{}{}
+	}
+	p.attemptTimeout = conf.AttemptTimeout
+	return &p
+}
+
+func statusCodes(codes ...int) map[int]struct{} {
+	m := make(map[int]struct{}, len(codes))
+	for _, code := range codes {
+		m[code] = struct{}{}
+	}
+	return m
+}
+
+func (p *retryPolicy) retryableStatus(code int) bool {
+	_, ok := p.statusCodes[code]
+	return ok
+}
+
+// delay returns the backoff before a retry, counting retries from 1.
+// With jitter, it is randomized over the upper half, so that the minimum delay still holds.
+func (p *retryPolicy) delay(retry int) time.Duration {
+	d := p.minDelay
+	for i := 1; i < retry && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	if p.jitter && d > 1 {
+		d = d/2 + rand.N(d/2)
+	}
+	return d
+}
+
+// wait sleeps before a retry, returning early if the context is done
+func (p *retryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(p.delay(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// withAttemptTimeout returns a copy of client which bounds each request by the attempt timeout
+func (p *retryPolicy) withAttemptTimeout(client *http.Client) *http.Client {
+	if p.attemptTimeout <= 0 {
+		return client
+	}
+
+	c := *client
+	c.Transport = &attemptTimeoutTransport{base: transportOrDefault(client.Transport), timeout: p.attemptTimeout}
+	return &c
+}
+
+func transportOrDefault(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		return http.DefaultTransport
+	}
+	return transport
+}
+
+// attemptTimeoutTransport cancels requests, including reading their response body, after a timeout
+type attemptTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *attemptTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *attemptTimeoutTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryTransport retries requests for sdks without a retryer of their own.
+// Requests with a body are only retried if it can be replayed.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy *retryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if !replayable || attempt >= t.policy.maxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil {
+			if !t.policy.retryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			// drained so that the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		} else if !isRetryableNetError(err) {
+			return nil, err
+		}
+
+		if err = t.policy.wait(req.Context(), attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// isRetryableNetError reports whether a request failed on the network, rather than being canceled
+func isRetryableNetError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// retryableStatusError reports a response with a configured retryable status as a temporary network error,
+// for sdks that only retry on their own fixed set of statuses
+type retryableStatusError struct {
+	status string
+}
+
+func (e *retryableStatusError) Error() string   { return "retryable response status: " + e.status }
+func (e *retryableStatusError) Timeout() bool   { return false }
+func (e *retryableStatusError) Temporary() bool { return true }
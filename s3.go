@@ -17,13 +17,17 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -35,6 +39,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/middleware"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
@@ -42,14 +47,33 @@ import (
 const defaultBucketLocation = "us-east-1"
 
 type s3Storage struct {
-	conf    *S3Config
-	awsConf *aws.Config
+	conf       *S3Config
+	awsConf    *aws.Config
+	httpClient *http.Client
+	client     atomic.Pointer[s3.Client] // replaced once EnsureBucket learns the region
+	limiter    *RateLimiter
 }
 
 func NewS3(conf *S3Config) (Storage, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
 	var cp aws.CredentialsProvider
 
-	if conf.AccessKey != "" && conf.Secret != "" {
+	provider := conf.CredentialsProvider
+	if provider == nil && conf.AccessKey != "" && conf.Secret != "" &&
+		(isSecretRef(conf.AccessKey) || isSecretRef(conf.Secret) || isSecretRef(conf.SessionToken)) {
+		provider = &secretCredentials{
+			accessKey:    newSecret(conf.AccessKey),
+			secret:       newSecret(conf.Secret),
+			sessionToken: newSecret(conf.SessionToken),
+		}
+	}
+
+	if provider != nil {
+		cp = &s3Credentials{provider: provider}
+	} else if conf.AccessKey != "" && conf.Secret != "" {
 		cp = credentials.StaticCredentialsProvider{
 			Value: aws.Credentials{
 				AccessKeyID:     conf.AccessKey,
@@ -59,7 +83,24 @@ func NewS3(conf *S3Config) (Storage, error) {
 		}
 	}
 
-	awsConf, err := getConf(conf, cp)
+	// the bundle only applies to the clients built here, a custom HTTPClient keeps its own roots
+	tlsConf := conf.TLSConfig
+	if caBundle := os.Getenv("AWS_CA_BUNDLE"); caBundle != "" && (tlsConf == nil || tlsConf.CAFile == "") {
+		withBundle := TLSConfig{}
+		if tlsConf != nil {
+			withBundle = *tlsConf
+		}
+		withBundle.CAFile = caBundle
+		tlsConf = &withBundle
+	}
+
+	httpClient, err := newHTTPClient(conf.HTTPClient, conf.ProxyConfig, tlsConf, conf.TransportConfig)
+	if err != nil {
+		return nil, err
+	}
+	httpClient = s3RetryPolicy(conf).withAttemptTimeout(httpClient)
+
+	awsConf, err := getConf(conf, cp, httpClient)
 	if err != nil {
 		return nil, err
 	}
@@ -71,25 +112,86 @@ func NewS3(conf *S3Config) (Storage, error) {
 				o.ExternalID = aws.String(conf.AssumeRoleExternalId)
 			}
 		})
-		awsConf, err = getConf(conf, cp)
+		awsConf, err = getConf(conf, cp, httpClient)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	if conf.Region == "" && conf.Endpoint == "" {
-		if err = updateRegion(awsConf, conf.Bucket); err != nil {
+		ctx, cancel := conf.TimeoutConfig.context(timeoutConnect)
+		err = timeoutError(ctx, updateRegion(ctx, awsConf, conf.Bucket))
+		cancel()
+		if err != nil {
+			if conf.HTTPClient == nil {
+				httpClient.CloseIdleConnections()
+			}
 			return nil, err
 		}
 	}
 
-	return &s3Storage{
-		conf:    conf,
-		awsConf: awsConf,
+	s := &s3Storage{
+		conf:       conf,
+		awsConf:    awsConf,
+		httpClient: httpClient,
+		limiter:    newConfigRateLimiter(conf.MaxBytesPerSecond, conf.RateLimiter),
+	}
+	s.client.Store(s.newClient(awsConf.Region))
+	return s, nil
+}
+
+func (s *s3Storage) Capabilities() Capabilities {
+	return Capabilities{
+		PresignedURLs:  true,
+		ServerSideCopy: true,
+		Versioning:     true,
+		BatchDelete:    true,
+		NativeAppend:   strings.HasSuffix(s.conf.Bucket, "--x-s3"),
+		Lifecycle:      true,
+		CORS:           true,
+	}
+}
+
+// s3Credentials adapts a CredentialsProvider, which the sdk consults whenever the previous credentials expire
+type s3Credentials struct {
+	provider CredentialsProvider
+}
+
+func (c *s3Credentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKey,
+		SecretAccessKey: creds.Secret,
+		SessionToken:    creds.SessionToken,
+		CanExpire:       true,
+		Expires:         creds.expires(),
 	}, nil
 }
 
-func getConf(conf *S3Config, cp aws.CredentialsProvider) (*aws.Config, error) {
+// s3RetryPolicy maps the retry config, or the deprecated retry fields, over the sdk defaults
+func s3RetryPolicy(conf *S3Config) *retryPolicy {
+	retryConf := conf.RetryConfig
+	if retryConf == nil {
+		retryConf = &RetryConfig{
+			MaxAttempts: conf.MaxRetries,
+			MinDelay:    conf.MinRetryDelay,
+			MaxDelay:    conf.MaxRetryDelay,
+		}
+	}
+	return newRetryPolicy(retryConf, retryPolicy{
+		maxAttempts: retry.DefaultMaxAttempts,
+		minDelay:    time.Second, // the base of the sdk's exponential backoff
+		maxDelay:    retry.DefaultMaxBackoff,
+		// in addition to the sdk's own throttling and server error handling
+		statusCodes: statusCodes(http.StatusConflict, http.StatusTooManyRequests),
+	})
+}
+
+func getConf(conf *S3Config, cp aws.CredentialsProvider, httpClient *http.Client) (*aws.Config, error) {
 	opts := func(o *config.LoadOptions) error {
 		if conf.Region != "" {
 			o.Region = conf.Region
@@ -98,35 +200,20 @@ func getConf(conf *S3Config, cp aws.CredentialsProvider) (*aws.Config, error) {
 		}
 
 		o.Credentials = cp
+		policy := s3RetryPolicy(conf)
 		o.Retryer = func() aws.Retryer {
 			return retry.NewStandard(func(o *retry.StandardOptions) {
-				o.MaxAttempts = conf.MaxRetries
-				o.MaxBackoff = conf.MaxRetryDelay
+				o.MaxAttempts = policy.maxAttempts
+				o.MaxBackoff = policy.maxDelay
+				o.Backoff = retry.BackoffDelayerFunc(func(attempt int, _ error) (time.Duration, error) {
+					return policy.delay(attempt), nil
+				})
 				o.Retryables = append(o.Retryables, retry.RetryableHTTPStatusCode{
-					Codes: map[int]struct{}{
-						http.StatusConflict:        {},
-						http.StatusTooManyRequests: {},
-					},
+					Codes: policy.statusCodes,
 				})
 			})
 		}
 
-		if conf.ProxyConfig != nil {
-			proxyUrl, err := url.Parse(conf.ProxyConfig.Url)
-			if err != nil {
-				return err
-			}
-			s3Transport := http.DefaultTransport.(*http.Transport).Clone()
-			s3Transport.Proxy = http.ProxyURL(proxyUrl)
-			if conf.ProxyConfig.Username != "" && conf.ProxyConfig.Password != "" {
-				auth := fmt.Sprintf("%s:%s", conf.ProxyConfig.Username, conf.ProxyConfig.Password)
-				basicAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-				s3Transport.ProxyConnectHeader = http.Header{}
-				s3Transport.ProxyConnectHeader.Add("Proxy-Authorization", basicAuth)
-			}
-			o.HTTPClient = &http.Client{Transport: s3Transport}
-		}
-
 		return nil
 	}
 
@@ -134,6 +221,8 @@ func getConf(conf *S3Config, cp aws.CredentialsProvider) (*aws.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	// set afterwards, since the sdk rejects custom clients when AWS_CA_BUNDLE is set. The bundle is added to our tls config instead.
+	awsConf.HTTPClient = httpClient
 
 	if conf.Endpoint != "" {
 		awsConf.BaseEndpoint = &conf.Endpoint
@@ -142,13 +231,18 @@ func getConf(conf *S3Config, cp aws.CredentialsProvider) (*aws.Config, error) {
 	return &awsConf, nil
 }
 
-func updateRegion(awsConf *aws.Config, bucket string) error {
+func updateRegion(ctx context.Context, awsConf *aws.Config, bucket string) error {
 	req := &s3.GetBucketLocationInput{
 		Bucket: &bucket,
 	}
 
-	resp, err := s3.NewFromConfig(*awsConf).GetBucketLocation(context.Background(), req)
+	resp, err := s3.NewFromConfig(*awsConf).GetBucketLocation(ctx, req)
 	if err != nil {
+		// the bucket may not have been created yet, see EnsureBucket
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket" {
+			return nil
+		}
 		return err
 	}
 
@@ -159,15 +253,30 @@ func updateRegion(awsConf *aws.Config, bucket string) error {
 	return nil
 }
 
-func (s *s3Storage) UploadData(data []byte, storagePath, contentType string) (string, int64, error) {
-	location, err := s.upload(bytes.NewReader(data), storagePath, contentType)
-	if err != nil {
+func (s *s3Storage) newClient(region string) *s3.Client {
+	return s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
+		o.Region = region
+		o.UsePathStyle = s.conf.ForcePathStyle
+	})
+}
+
+func (s *s3Storage) UploadData(data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.uploadData(context.Background(), data, storagePath, contentType, opts...)
+}
+
+func (s *s3Storage) uploadData(ctx context.Context, data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutUpload)
+	defer cancel()
+
+	size := int64(len(data))
+	location, err := s.upload(ctx, bytes.NewReader(data), size, storagePath, contentType, s3Condition{}, getOptions(ctx, s.limiter, opts))
+	if err = timeoutError(ctx, err); err != nil {
 		return "", 0, err
 	}
-	return location, int64(len(data)), nil
+	return location, size, nil
 }
 
-func (s *s3Storage) UploadFile(filepath, storagePath, contentType string) (string, int64, error) {
+func (s *s3Storage) UploadFile(filepath, storagePath, contentType string, opts ...Option) (string, int64, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return "", 0, err
@@ -181,37 +290,42 @@ func (s *s3Storage) UploadFile(filepath, storagePath, contentType string) (strin
 		return "", 0, err
 	}
 
-	location, err := s.upload(file, storagePath, contentType)
-	if err != nil {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+
+	location, err := s.upload(ctx, file, stat.Size(), storagePath, contentType, s3Condition{}, getOptions(ctx, s.limiter, opts))
+	if err = timeoutError(ctx, err); err != nil {
 		return "", 0, err
 	}
 
 	return location, stat.Size(), nil
 }
 
-func (s *s3Storage) upload(reader io.Reader, storagePath, contentType string) (string, error) {
+// s3Condition restricts an upload to replacing a given version of the object, or to creating it
+type s3Condition struct {
+	ifMatch     *string
+	ifNoneMatch *string
+}
+
+func (s *s3Storage) upload(ctx context.Context, reader io.ReadSeeker, size int64, storagePath, contentType string, cond s3Condition, o *options) (string, error) {
+	contentType, err := resolveContentType(contentType, storagePath, reader)
+	if err != nil {
+		return "", err
+	}
+	checksums, err := o.uploadChecksums(reader)
+	if err != nil {
+		return "", err
+	}
+
 	l := NewS3Logger()
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
+	clientOpts := func(o *s3.Options) {
 		o.Logger = l
 		o.ClientLogMode = aws.LogRequest | aws.LogResponse | aws.LogRetries
-		o.UsePathStyle = s.conf.ForcePathStyle
-
-		// switch to md5 checksum for oracle cloud
-		if s.conf.Endpoint != "" {
-			if parsed, err := url.Parse(s.conf.Endpoint); err == nil && strings.HasSuffix(parsed.Host, "oraclecloud.com") {
-				o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
-					stack.Initialize.Remove("AWSChecksum:SetupInputContext")
-					stack.Build.Remove("AWSChecksum:RequestMetricsTracking")
-					stack.Finalize.Remove("AWSChecksum:ComputeInputPayloadChecksum")
-					stack.Finalize.Remove("addInputChecksumTrailer")
-					return smithyhttp.AddContentChecksumMiddleware(stack)
-				})
-			}
-		}
-	})
+		s.checksumOptions(o)
+	}
 
 	input := &s3.PutObjectInput{
-		Body:        reader,
+		Body:        o.reader(reader, size),
 		Bucket:      aws.String(s.conf.Bucket),
 		ContentType: aws.String(contentType),
 		Key:         aws.String(storagePath),
@@ -220,50 +334,186 @@ func (s *s3Storage) upload(reader io.Reader, storagePath, contentType string) (s
 	if s.conf.Tagging != "" {
 		input.Tagging = &s.conf.Tagging
 	}
-	if s.conf.ContentDisposition != "" {
-		input.ContentDisposition = &s.conf.ContentDisposition
-	} else {
-		contentDisposition := "inline"
-		input.ContentDisposition = &contentDisposition
+	input.ContentDisposition = s.contentDisposition()
+	if checksums != nil && size < manager.DefaultUploadPartSize {
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(checksums.MD5))
+		if !s.isOracle() {
+			input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(checksums.SHA256))
+		}
+	} else if checksums != nil && !s.isOracle() {
+		// multipart uploads can only be validated part by part
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
 	}
 
-	if _, err := manager.NewUploader(client).Upload(context.Background(), input); err != nil {
+	uploader := manager.NewUploader(s.client.Load(), func(u *manager.Uploader) {
+		u.ClientOptions = append(u.ClientOptions, clientOpts)
+		if cond != (s3Condition{}) {
+			// conditions are only sent with single part uploads
+			input.IfMatch = cond.ifMatch
+			input.IfNoneMatch = cond.ifNoneMatch
+			u.PartSize = max(u.PartSize, size+1)
+		}
+	})
+	if _, err := uploader.Upload(ctx, input); err != nil {
 		return "", err
 	}
 
+	return s.location(storagePath), nil
+}
+
+// checksumOptions switches to md5 checksum for oracle cloud
+func (s *s3Storage) checksumOptions(o *s3.Options) {
+	if s.isOracle() {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			stack.Initialize.Remove("AWSChecksum:SetupInputContext")
+			stack.Build.Remove("AWSChecksum:RequestMetricsTracking")
+			stack.Finalize.Remove("AWSChecksum:ComputeInputPayloadChecksum")
+			stack.Finalize.Remove("addInputChecksumTrailer")
+			return smithyhttp.AddContentChecksumMiddleware(stack)
+		})
+	}
+}
+
+func (s *s3Storage) isOracle() bool {
+	if s.conf.Endpoint == "" {
+		return false
+	}
+	parsed, err := url.Parse(s.conf.Endpoint)
+	return err == nil && strings.HasSuffix(parsed.Host, "oraclecloud.com")
+}
+
+func (s *s3Storage) contentDisposition() *string {
+	if s.conf.ContentDisposition != "" {
+		return &s.conf.ContentDisposition
+	}
+	return aws.String("inline")
+}
+
+func (s *s3Storage) location(storagePath string) string {
 	endpoint := "s3.amazonaws.com"
 	if s.conf.Endpoint != "" {
 		endpoint = s.conf.Endpoint
 	}
 
-	var location string
 	if s.conf.ForcePathStyle {
 		if !strings.HasPrefix(endpoint, "http") {
 			endpoint = "https://" + endpoint
 		}
-		location = fmt.Sprintf("%s/%s/%s", endpoint, s.conf.Bucket, storagePath)
-	} else {
-		location = fmt.Sprintf("https://%s.%s/%s", s.conf.Bucket, endpoint, storagePath)
+		return fmt.Sprintf("%s/%s/%s", endpoint, s.conf.Bucket, storagePath)
+	}
+	return fmt.Sprintf("https://%s.%s/%s", s.conf.Bucket, endpoint, storagePath)
+}
+
+func (s *s3Storage) UploadFileResumable(j *Journal, filepath, storagePath, contentType string, opts ...Option) (_ string, _ int64, err error) {
+	state, file, err := j.prepare(backendS3, s.conf.Bucket, filepath, storagePath, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+	o := getOptions(ctx, s.limiter, opts)
+
+	if state.UploadID == "" {
+		input := &s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(s.conf.Bucket),
+			Key:                aws.String(storagePath),
+			ContentType:        aws.String(state.ContentType),
+			ContentDisposition: s.contentDisposition(),
+			Metadata:           s.conf.Metadata,
+		}
+		if s.conf.Tagging != "" {
+			input.Tagging = &s.conf.Tagging
+		}
+
+		resp, err := s.client.Load().CreateMultipartUpload(ctx, input, s.checksumOptions)
+		if err != nil {
+			return "", 0, err
+		}
+
+		state.UploadID = aws.ToString(resp.UploadId)
+		state.PartSize = partSize(state.Size, manager.DefaultUploadPartSize, int64(manager.MaxUploadParts))
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
 	}
 
-	return location, nil
+	// multipart uploads need at least one part, even for empty files
+	for len(state.Parts) == 0 || int64(len(state.Parts))*state.PartSize < state.Size {
+		offset := int64(len(state.Parts)) * state.PartSize
+		buf, err := readPart(file, offset, min(state.PartSize, state.Size-offset), o)
+		if err != nil {
+			return "", 0, err
+		}
+
+		number := int32(len(state.Parts) + 1)
+		resp, err := s.client.Load().UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.conf.Bucket),
+			Key:        aws.String(storagePath),
+			UploadId:   aws.String(state.UploadID),
+			PartNumber: aws.Int32(number),
+			Body:       bytes.NewReader(buf),
+		}, s.checksumOptions)
+		if err != nil {
+			return "", 0, err
+		}
+
+		state.Parts = append(state.Parts, UploadedPart{Number: number, ETag: aws.ToString(resp.ETag)})
+		if err = j.save(state); err != nil {
+			return "", 0, err
+		}
+		if o.progress != nil {
+			o.progress(offset+int64(len(buf)), state.Size)
+		}
+	}
+
+	parts := make([]types.CompletedPart, 0, len(state.Parts))
+	for _, part := range state.Parts {
+		parts = append(parts, types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.Number),
+		})
+	}
+	_, err = s.client.Load().CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.conf.Bucket),
+		Key:             aws.String(storagePath),
+		UploadId:        aws.String(state.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}, s.checksumOptions)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err = j.Remove(state); err != nil {
+		return "", 0, err
+	}
+	return s.location(storagePath), state.Size, nil
+}
+
+func (s *s3Storage) ResumeUpload(j *Journal, state *UploadState, opts ...Option) (string, int64, error) {
+	return resumeUpload(s, backendS3, s.conf.Bucket, j, state, opts)
 }
 
 func (s *s3Storage) ListObjects(prefix string) ([]string, error) {
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
-		o.UsePathStyle = s.conf.ForcePathStyle
-	})
+	return s.listObjects(context.Background(), prefix)
+}
+
+func (s *s3Storage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutList)
+	defer cancel()
 
 	var objects []string
-	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(s.client.Load(), &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.conf.Bucket),
 		Prefix: aws.String(prefix),
 	})
 
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(context.Background())
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, timeoutError(ctx, err)
 		}
 
 		for _, obj := range page.Contents {
@@ -274,73 +524,400 @@ func (s *s3Storage) ListObjects(prefix string) ([]string, error) {
 	return objects, nil
 }
 
-func (s *s3Storage) DownloadData(storagePath string) ([]byte, error) {
+func (s *s3Storage) DownloadData(storagePath string, opts ...Option) ([]byte, error) {
+	return s.downloadData(context.Background(), storagePath, opts...)
+}
+
+func (s *s3Storage) downloadData(ctx context.Context, storagePath string, opts ...Option) ([]byte, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutDownload)
+	defer cancel()
+
+	o := getOptions(ctx, s.limiter, opts)
 	w := &manager.WriteAtBuffer{}
-	_, err := s.download(w, storagePath)
+	_, expected, err := s.download(ctx, w, storagePath, o)
 	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	if err = o.verifyChecksums(bytes.NewReader(w.Bytes()), expected); err != nil {
 		return nil, err
 	}
 
 	return w.Bytes(), nil
 }
 
-func (s *s3Storage) DownloadFile(filepath, storagePath string) (int64, error) {
+func (s *s3Storage) DownloadFile(filepath, storagePath string, opts ...Option) (int64, error) {
 	file, err := os.Create(filepath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
-	return s.download(file, storagePath)
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutDownload)
+	defer cancel()
+
+	o := getOptions(ctx, s.limiter, opts)
+	size, expected, err := s.download(ctx, file, storagePath, o)
+	if err != nil {
+		return 0, timeoutError(ctx, err)
+	}
+	if err = o.verifyFile(filepath, expected); err != nil {
+		return 0, err
+	}
+
+	return size, nil
 }
 
-func (s *s3Storage) download(w io.WriterAt, storagePath string) (int64, error) {
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
-		o.UsePathStyle = s.conf.ForcePathStyle
+func (s *s3Storage) download(ctx context.Context, w io.WriterAt, storagePath string, o *options) (int64, *Checksums, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(storagePath),
+	}
+
+	size := int64(-1)
+	var expected *Checksums
+	if o.progress != nil || o.checksums != nil {
+		// the downloader does not expose the object size or checksums, so look them up
+		head, err := s.client.Load().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(s.conf.Bucket),
+			Key:          aws.String(storagePath),
+			ChecksumMode: types.ChecksumModeEnabled,
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		size = aws.ToInt64(head.ContentLength)
+		expected = s3Checksums(head)
+		input.IfMatch = head.ETag
+	}
+	w = o.writerAt(w, size)
+
+	n, err := manager.NewDownloader(s.client.Load()).Download(ctx, w, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, expected, nil
+}
+
+// s3Checksums returns the full object checksums of an object.
+// Multipart uploads only have checksums of their parts, and their ETag is not an MD5.
+func s3Checksums(head *s3.HeadObjectOutput) *Checksums {
+	c := &Checksums{}
+	decode := func(checksum *string) []byte {
+		if checksum == nil || strings.Contains(*checksum, "-") {
+			return nil
+		}
+		b, _ := base64.StdEncoding.DecodeString(*checksum)
+		return b
+	}
+	c.CRC32C = decode(head.ChecksumCRC32C)
+	c.SHA256 = decode(head.ChecksumSHA256)
+
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	if len(etag) == md5.Size*2 && head.ServerSideEncryption != types.ServerSideEncryptionAwsKms && head.SSECustomerAlgorithm == nil {
+		c.MD5, _ = hex.DecodeString(etag)
+	}
+	return c
+}
+
+// Append uses native appends on S3 Express directory buckets.
+// Elsewhere the object is recomposed with a multipart upload copying the existing data server-side,
+// or re-uploaded if it is smaller than the minimum part size.
+func (s *s3Storage) Append(storagePath string, data []byte) (_ int64, err error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	head, err := s.client.Load().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(storagePath),
 	})
+	if err != nil {
+		var notFound *types.NotFound
+		if !errors.As(err, &notFound) {
+			return 0, err
+		}
+		// only create the object if no other append did meanwhile
+		_, err = s.upload(ctx, bytes.NewReader(data), int64(len(data)), storagePath, "", s3Condition{ifNoneMatch: aws.String("*")}, getOptions(ctx, s.limiter, nil))
+		if err != nil {
+			return 0, s3AppendError(err)
+		}
+		return int64(len(data)), nil
+	}
 
-	return manager.NewDownloader(client).Download(
-		context.Background(),
-		w,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.conf.Bucket),
-			Key:    aws.String(storagePath),
+	size := aws.ToInt64(head.ContentLength)
+	if len(data) == 0 {
+		return size, nil
+	}
+
+	switch {
+	case strings.HasSuffix(s.conf.Bucket, "--x-s3"):
+		_, err = s.client.Load().PutObject(ctx, &s3.PutObjectInput{
+			Bucket:           aws.String(s.conf.Bucket),
+			Key:              aws.String(storagePath),
+			Body:             bytes.NewReader(data),
+			WriteOffsetBytes: aws.Int64(size),
+		})
+		if err != nil {
+			return 0, err
+		}
+
+	case size < manager.MinUploadPartSize:
+		existing, err := s.DownloadData(storagePath)
+		if err != nil {
+			return 0, err
+		}
+		// the object is rewritten only if nobody changed it since it was read
+		if _, err = s.upload(ctx, bytes.NewReader(append(existing, data...)), size+int64(len(data)), storagePath, aws.ToString(head.ContentType), s3Condition{ifMatch: head.ETag}, getOptions(ctx, nil, nil)); err != nil {
+			return 0, s3AppendError(err)
+		}
+
+	default:
+		if err = s.appendMultipart(ctx, storagePath, head, data); err != nil {
+			return 0, s3AppendError(err)
+		}
+	}
+
+	return size + int64(len(data)), nil
+}
+
+// s3AppendError reports failed preconditions as ErrAppendConflict
+func s3AppendError(err error) error {
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %w", ErrAppendConflict, err)
+	}
+	return err
+}
+
+func (s *s3Storage) appendMultipart(ctx context.Context, storagePath string, head *s3.HeadObjectOutput, data []byte) error {
+	create, err := s.client.Load().CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s.conf.Bucket),
+		Key:                aws.String(storagePath),
+		ContentType:        head.ContentType,
+		ContentDisposition: head.ContentDisposition,
+		Metadata:           head.Metadata,
+	}, s.checksumOptions)
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		_, _ = s.client.Load().AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.conf.Bucket),
+			Key:      aws.String(storagePath),
+			UploadId: create.UploadId,
+		})
+		return err
+	}
+
+	// fail if the object is modified concurrently
+	copied, err := s.client.Load().UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket:            aws.String(s.conf.Bucket),
+		Key:               aws.String(storagePath),
+		UploadId:          create.UploadId,
+		PartNumber:        aws.Int32(1),
+		CopySource:        aws.String(s.conf.Bucket + "/" + url.PathEscape(storagePath)),
+		CopySourceIfMatch: head.ETag,
+	})
+	if err != nil {
+		return abort(err)
+	}
+
+	uploaded, err := s.client.Load().UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.conf.Bucket),
+		Key:        aws.String(storagePath),
+		UploadId:   create.UploadId,
+		PartNumber: aws.Int32(2),
+		Body:       bytes.NewReader(data),
+	}, s.checksumOptions)
+	if err != nil {
+		return abort(err)
+	}
+
+	// and if it is replaced after the copy
+	_, err = s.client.Load().CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.conf.Bucket),
+		Key:      aws.String(storagePath),
+		UploadId: create.UploadId,
+		IfMatch:  head.ETag,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{
+				{ETag: copied.CopyPartResult.ETag, PartNumber: aws.Int32(1)},
+				{ETag: uploaded.ETag, PartNumber: aws.Int32(2)},
+			},
 		},
-	)
+	}, s.checksumOptions)
+	if err != nil {
+		return abort(err)
+	}
+
+	return nil
 }
 
-func (s *s3Storage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (string, error) {
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
-		o.UsePathStyle = s.conf.ForcePathStyle
+// ConcatObjects copies the sources into the parts of a multipart upload.
+// Sources under the minimum part size are downloaded and merged with their neighbours.
+func (s *s3Storage) ConcatObjects(dst string, srcs ...string) (err error) {
+	if len(srcs) == 0 {
+		return ErrNoSources
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutUpload)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	heads := make([]*s3.HeadObjectOutput, len(srcs))
+	sizes := make([]int64, len(srcs))
+	for i, src := range srcs {
+		head, err := s.client.Load().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.conf.Bucket),
+			Key:    aws.String(src),
+		})
+		if err != nil {
+			return err
+		}
+		heads[i] = head
+		sizes[i] = aws.ToInt64(head.ContentLength)
+	}
+
+	parts := planConcat(sizes, manager.MinUploadPartSize, 5<<30)
+	if len(parts) == 0 {
+		_, err := s.upload(ctx, bytes.NewReader(nil), 0, dst, aws.ToString(heads[0].ContentType), s3Condition{}, getOptions(ctx, nil, nil))
+		return err
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s.conf.Bucket),
+		Key:                aws.String(dst),
+		ContentType:        heads[0].ContentType,
+		ContentDisposition: s.contentDisposition(),
+		Metadata:           s.conf.Metadata,
+	}
+	if s.conf.Tagging != "" {
+		input.Tagging = &s.conf.Tagging
+	}
+	create, err := s.client.Load().CreateMultipartUpload(ctx, input, s.checksumOptions)
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		_, _ = s.client.Load().AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.conf.Bucket),
+			Key:      aws.String(dst),
+			UploadId: create.UploadId,
+		})
+		return err
+	}
+
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for i, part := range parts {
+		partNumber := aws.Int32(int32(i + 1))
+		var etag *string
+		if part.copy {
+			r := part.ranges[0]
+			// fail if a source is modified concurrently
+			res, err := s.client.Load().UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:            aws.String(s.conf.Bucket),
+				Key:               aws.String(dst),
+				UploadId:          create.UploadId,
+				PartNumber:        partNumber,
+				CopySource:        aws.String(s.conf.Bucket + "/" + url.PathEscape(srcs[r.src])),
+				CopySourceIfMatch: heads[r.src].ETag,
+				CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+r.length-1)),
+			})
+			if err != nil {
+				return abort(err)
+			}
+			etag = res.CopyPartResult.ETag
+		} else {
+			var buf []byte
+			for _, r := range part.ranges {
+				data, err := s.downloadRange(ctx, srcs[r.src], heads[r.src].ETag, r.offset, r.length)
+				if err != nil {
+					return abort(err)
+				}
+				buf = append(buf, data...)
+			}
+			res, err := s.client.Load().UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:     aws.String(s.conf.Bucket),
+				Key:        aws.String(dst),
+				UploadId:   create.UploadId,
+				PartNumber: partNumber,
+				Body:       bytes.NewReader(buf),
+			}, s.checksumOptions)
+			if err != nil {
+				return abort(err)
+			}
+			etag = res.ETag
+		}
+		completed = append(completed, types.CompletedPart{ETag: etag, PartNumber: partNumber})
+	}
+
+	_, err = s.client.Load().CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.conf.Bucket),
+		Key:             aws.String(dst),
+		UploadId:        create.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	}, s.checksumOptions)
+	if err != nil {
+		return abort(err)
+	}
+
+	return nil
+}
+
+func (s *s3Storage) downloadRange(ctx context.Context, storagePath string, etag *string, offset, length int64) ([]byte, error) {
+	res, err := s.client.Load().GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(s.conf.Bucket),
+		Key:     aws.String(storagePath),
+		IfMatch: etag,
+		Range:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
 	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(res.Body, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
 
-	res, err := s3.NewPresignClient(client).PresignGetObject(context.Background(), &s3.GetObjectInput{
+func (s *s3Storage) GeneratePresignedUrl(storagePath string, expiration time.Duration) (string, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutPresign)
+	defer cancel()
+
+	res, err := s3.NewPresignClient(s.client.Load()).PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.conf.Bucket),
 		Key:    aws.String(storagePath),
 	}, s3.WithPresignExpires(expiration))
 	if err != nil {
-		return "", err
+		return "", timeoutError(ctx, err)
 	}
 
 	return res.URL, nil
 }
 
 func (s *s3Storage) DeleteObject(storagePath string) error {
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
-		o.UsePathStyle = s.conf.ForcePathStyle
-	})
+	return s.deleteObject(context.Background(), storagePath)
+}
 
-	_, err := client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+func (s *s3Storage) deleteObject(ctx context.Context, storagePath string) error {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	_, err := s.client.Load().DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.conf.Bucket),
 		Key:    aws.String(storagePath),
 	})
-	return err
+	return timeoutError(ctx, err)
 }
 
 func (s *s3Storage) DeleteObjects(storagePaths []string) error {
-	client := s3.NewFromConfig(*s.awsConf, func(o *s3.Options) {
-		o.UsePathStyle = s.conf.ForcePathStyle
-	})
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
 
 	for i := 0; i < len(storagePaths); i += 1000 {
 		end := i + 1000
@@ -353,7 +930,7 @@ func (s *s3Storage) DeleteObjects(storagePaths []string) error {
 			objects = append(objects, types.ObjectIdentifier{Key: aws.String(path)})
 		}
 
-		_, err := client.DeleteObjects(context.Background(), &s3.DeleteObjectsInput{
+		_, err := s.client.Load().DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(s.conf.Bucket),
 			Delete: &types.Delete{
 				Objects: objects,
@@ -361,9 +938,310 @@ func (s *s3Storage) DeleteObjects(storagePaths []string) error {
 			},
 		})
 		if err != nil {
-			return err
+			return timeoutError(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *s3Storage) BucketExists() (bool, error) {
+	return s.bucketExists(context.Background())
+}
+
+func (s *s3Storage) bucketExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.conf.TimeoutConfig.contextFrom(ctx, timeoutMetadata)
+	defer cancel()
+
+	_, err := s.client.Load().HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.conf.Bucket),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, timeoutError(ctx, err)
+	}
+
+	return true, nil
+}
+
+func (s *s3Storage) EnsureBucket(opts *BucketOptions) (err error) {
+	if opts == nil {
+		opts = &BucketOptions{}
+	}
+
+	exists, err := s.BucketExists()
+	if err != nil || exists {
+		return err
+	}
+
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+	defer wrapTimeout(ctx, &err)
+
+	client := s.client.Load()
+	region := opts.Region
+	if region == "" {
+		region = client.Options().Region
+	}
+
+	withRegion := func(o *s3.Options) {
+		o.Region = region
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(s.conf.Bucket),
+	}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if region != defaultBucketLocation {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if _, err = client.CreateBucket(ctx, input, withRegion); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			return nil
+		}
+		var taken *types.BucketAlreadyExists
+		if errors.As(err, &taken) {
+			return fmt.Errorf("%w: %w", ErrBucketTaken, err)
 		}
+		return err
+	}
+
+	// region was unknown when the storage was created
+	if s.conf.Region == "" && s.conf.Endpoint == "" && client.Options().Region != region {
+		client = s.newClient(region)
+		s.client.Store(client)
+	}
+
+	if !opts.DefaultEncryption && opts.KMSKeyID == "" {
+		return nil
+	}
+
+	sse := &types.ServerSideEncryptionByDefault{
+		SSEAlgorithm: types.ServerSideEncryptionAes256,
+	}
+	if opts.KMSKeyID != "" {
+		sse.SSEAlgorithm = types.ServerSideEncryptionAwsKms
+		sse.KMSMasterKeyID = aws.String(opts.KMSKeyID)
 	}
 
+	_, err = client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(s.conf.Bucket),
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: sse,
+			}},
+		},
+	}, withRegion)
+	return err
+}
+
+func (s *s3Storage) GetLifecycleRules() ([]LifecycleRule, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	rules, _, err := s.lifecycleRules(ctx)
+	return rules, timeoutError(ctx, err)
+}
+
+// lifecycleRules returns the representable rules of the bucket, and whether there were no others
+func (s *s3Storage) lifecycleRules(ctx context.Context) ([]LifecycleRule, bool, error) {
+	resp, err := s.client.Load().GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.conf.Bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration" {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+
+	rules := make([]LifecycleRule, 0, len(resp.Rules))
+	for _, r := range resp.Rules {
+		if rule, ok := s3LifecycleRule(r); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, len(rules) == len(resp.Rules), nil
+}
+
+// s3LifecycleRule converts r, unless it has settings a LifecycleRule cannot hold
+func s3LifecycleRule(r types.LifecycleRule) (LifecycleRule, bool) {
+	if r.Status != types.ExpirationStatusEnabled || len(r.Transitions) > 1 ||
+		r.NoncurrentVersionExpiration != nil || len(r.NoncurrentVersionTransitions) > 0 {
+		return LifecycleRule{}, false
+	}
+
+	rule := LifecycleRule{
+		ID: aws.ToString(r.ID),
+	}
+	if f := r.Filter; f != nil {
+		if f.And != nil || f.Tag != nil || f.ObjectSizeGreaterThan != nil || f.ObjectSizeLessThan != nil {
+			return LifecycleRule{}, false
+		}
+		rule.Prefix = aws.ToString(f.Prefix)
+	} else {
+		rule.Prefix = aws.ToString(r.Prefix)
+	}
+	if r.Expiration != nil {
+		if r.Expiration.Date != nil || aws.ToBool(r.Expiration.ExpiredObjectDeleteMarker) {
+			return LifecycleRule{}, false
+		}
+		rule.ExpireAfterDays = int(aws.ToInt32(r.Expiration.Days))
+	}
+	if len(r.Transitions) == 1 {
+		if r.Transitions[0].Date != nil {
+			return LifecycleRule{}, false
+		}
+		rule.TransitionAfterDays = int(aws.ToInt32(r.Transitions[0].Days))
+		rule.TransitionStorageClass = string(r.Transitions[0].StorageClass)
+	}
+	if r.AbortIncompleteMultipartUpload != nil {
+		rule.AbortMultipartAfterDays = int(aws.ToInt32(r.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+	}
+	return rule, true
+}
+
+// PutLifecycleRules replaces the rules of the bucket, unless it holds rules GetLifecycleRules leaves out
+func (s *s3Storage) PutLifecycleRules(rules []LifecycleRule) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	if _, complete, err := s.lifecycleRules(ctx); err != nil {
+		return timeoutError(ctx, err)
+	} else if !complete {
+		return ErrLifecycleNotRepresentable
+	}
+
+	// s3 rejects an empty configuration, so clearing the rules requires a delete
+	if len(rules) == 0 {
+		_, err := s.client.Load().DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(s.conf.Bucket),
+		})
+		return timeoutError(ctx, err)
+	}
+
+	s3Rules := make([]types.LifecycleRule, 0, len(rules))
+	for i, rule := range rules {
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("rule-%d", i)
+		}
+
+		r := types.LifecycleRule{
+			ID:     aws.String(id),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{
+				Prefix: aws.String(rule.Prefix),
+			},
+		}
+		if rule.ExpireAfterDays > 0 {
+			r.Expiration = &types.LifecycleExpiration{
+				Days: aws.Int32(int32(rule.ExpireAfterDays)),
+			}
+		}
+		if rule.TransitionAfterDays > 0 {
+			r.Transitions = []types.Transition{{
+				Days:         aws.Int32(int32(rule.TransitionAfterDays)),
+				StorageClass: types.TransitionStorageClass(rule.TransitionStorageClass),
+			}}
+		}
+		if rule.AbortMultipartAfterDays > 0 {
+			r.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int32(int32(rule.AbortMultipartAfterDays)),
+			}
+		}
+		s3Rules = append(s3Rules, r)
+	}
+
+	_, err := s.client.Load().PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.conf.Bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: s3Rules,
+		},
+	})
+	return timeoutError(ctx, err)
+}
+
+func (s *s3Storage) GetCORSRules() ([]CORSRule, error) {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	resp, err := s.client.Load().GetBucketCors(ctx, &s3.GetBucketCorsInput{
+		Bucket: aws.String(s.conf.Bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchCORSConfiguration" {
+			return nil, nil
+		}
+		return nil, timeoutError(ctx, err)
+	}
+
+	rules := make([]CORSRule, 0, len(resp.CORSRules))
+	for _, r := range resp.CORSRules {
+		rules = append(rules, CORSRule{
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposedHeaders: r.ExposeHeaders,
+			MaxAgeSeconds:  int(aws.ToInt32(r.MaxAgeSeconds)),
+		})
+	}
+
+	return rules, nil
+}
+
+func (s *s3Storage) SetCORSRules(rules []CORSRule) error {
+	ctx, cancel := s.conf.TimeoutConfig.context(timeoutMetadata)
+	defer cancel()
+
+	// s3 rejects an empty configuration, so clearing the rules requires a delete
+	if len(rules) == 0 {
+		_, err := s.client.Load().DeleteBucketCors(ctx, &s3.DeleteBucketCorsInput{
+			Bucket: aws.String(s.conf.Bucket),
+		})
+		return timeoutError(ctx, err)
+	}
+
+	s3Rules := make([]types.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		r := types.CORSRule{
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposedHeaders,
+		}
+		if rule.MaxAgeSeconds > 0 {
+			r.MaxAgeSeconds = aws.Int32(int32(rule.MaxAgeSeconds))
+		}
+		s3Rules = append(s3Rules, r)
+	}
+
+	_, err := s.client.Load().PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket: aws.String(s.conf.Bucket),
+		CORSConfiguration: &types.CORSConfiguration{
+			CORSRules: s3Rules,
+		},
+	})
+	return timeoutError(ctx, err)
+}
+
+func (s *s3Storage) Validate(ctx context.Context) (*ValidationReport, error) {
+	return validate(ctx, s)
+}
+
+func (s *s3Storage) Close() error {
+	// a configured client is left to its owner
+	if s.conf.HTTPClient == nil {
+		s.httpClient.CloseIdleConnections()
+	}
 	return nil
 }
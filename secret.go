@@ -0,0 +1,98 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	secretFilePrefix = "file:"
+	secretEnvPrefix  = "env:"
+)
+
+// ResolveSecret returns the value of a secret config field, which can hold a reference instead of plaintext:
+//
+//	file:/path/to/secret  the file contents, trimmed of surrounding whitespace
+//	env:NAME              the value of an environment variable
+//
+// Storages re-read file references when the file changes, so that mounted secrets can rotate.
+func ResolveSecret(value string) (string, error) {
+	return newSecret(value).get()
+}
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretFilePrefix) || strings.HasPrefix(value, secretEnvPrefix)
+}
+
+// secret resolves a secret config field, caching file contents until the file changes
+type secret struct {
+	value string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	cached  string
+}
+
+func newSecret(value string) *secret {
+	return &secret{value: value}
+}
+
+func (s *secret) get() (string, error) {
+	switch {
+	case strings.HasPrefix(s.value, secretEnvPrefix):
+		name := strings.TrimPrefix(s.value, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(s.value, secretFilePrefix):
+		return s.readFile(strings.TrimPrefix(s.value, secretFilePrefix))
+
+	default:
+		return s.value, nil
+	}
+}
+
+func (s *secret) readFile(filename string) (string, error) {
+	// stat follows symlinks, so kubernetes secret mounts updating their ..data link are picked up
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != "" && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.cached, nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	s.cached = strings.TrimSpace(string(b))
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return s.cached, nil
+}
@@ -14,19 +14,84 @@
 
 package storage
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotSupported     = errors.New("operation not supported by this storage backend")
+	ErrNoBackend        = errors.New("no storage backend configured")
+	ErrMultipleBackends = errors.New("more than one storage backend configured")
+	ErrAppendConflict   = errors.New("object was modified during append")
+)
+
+// New returns the storage for the single backend set in conf
+func New(conf *StorageConfig) (Storage, error) {
+	if conf == nil {
+		return nil, ErrNoBackend
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case conf.S3 != nil:
+		return NewS3(conf.S3)
+	case conf.GCP != nil:
+		return NewGCP(conf.GCP)
+	case conf.Azure != nil:
+		return NewAzure(conf.Azure)
+	case conf.AliOSS != nil:
+		return NewAliOSS(conf.AliOSS)
+	default:
+		return NewLocal(conf.Local)
+	}
+}
 
 type Storage interface {
-	UploadData(data []byte, storagePath, contentType string) (location string, size int64, err error)
-	UploadFile(filepath, storagePath, contentType string) (location string, size int64, err error)
+	// Close releases the underlying clients and idle connections
+	io.Closer
+
+	Capabilities() Capabilities
+
+	// An empty contentType is detected from the storage path extension, or by sniffing the content
+	UploadData(data []byte, storagePath, contentType string, opts ...Option) (location string, size int64, err error)
+	UploadFile(filepath, storagePath, contentType string, opts ...Option) (location string, size int64, err error)
+
+	// UploadFileResumable uploads in parts, recording progress in the journal so that it can be resumed after a crash.
+	// If the journal already contains a matching upload, it is continued.
+	UploadFileResumable(j *Journal, filepath, storagePath, contentType string, opts ...Option) (location string, size int64, err error)
+	// ResumeUpload continues an interrupted upload returned by Journal.List
+	ResumeUpload(j *Journal, state *UploadState, opts ...Option) (location string, size int64, err error)
 
 	ListObjects(prefix string) ([]string, error)
 
-	DownloadData(storagePath string) (data []byte, err error)
-	DownloadFile(filepath, storagePath string) (size int64, err error)
+	// Append adds data to the end of an object, creating it if needed, and returns the new object size.
+	// Backends recomposing objects fail with ErrAppendConflict if the object is created or changed meanwhile.
+	Append(storagePath string, data []byte) (size int64, err error)
+	// ConcatObjects writes the sources, in order, to dst. The data is copied server-side where possible.
+	ConcatObjects(dst string, srcs ...string) error
+
+	DownloadData(storagePath string, opts ...Option) (data []byte, err error)
+	DownloadFile(filepath, storagePath string, opts ...Option) (size int64, err error)
 
 	GeneratePresignedUrl(storagePath string, expiration time.Duration) (url string, err error)
 
 	DeleteObject(storagePath string) error
 	DeleteObjects(storagePaths []string) error
+
+	BucketExists() (bool, error)
+	EnsureBucket(opts *BucketOptions) error
+
+	GetLifecycleRules() ([]LifecycleRule, error)
+	PutLifecycleRules(rules []LifecycleRule) error
+
+	GetCORSRules() ([]CORSRule, error)
+	SetCORSRules(rules []CORSRule) error
+
+	// Validate checks that the bucket exists and the credentials can write, read, list and delete objects
+	Validate(ctx context.Context) (*ValidationReport, error)
 }
@@ -0,0 +1,115 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter is a token bucket limiting transfer bandwidth.
+// A single limiter can be shared by several storages to enforce a node-wide cap.
+type RateLimiter struct {
+	limiter *rate.Limiter
+	burst   int
+}
+
+// NewRateLimiter returns a limiter allowing bytesPerSecond, with bursts of up to one second.
+// It returns nil, meaning no limit, if bytesPerSecond is not positive.
+func NewRateLimiter(bytesPerSecond int) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond),
+		burst:   bytesPerSecond,
+	}
+}
+
+func newConfigRateLimiter(bytesPerSecond int, limiter *RateLimiter) *RateLimiter {
+	if limiter != nil {
+		return limiter
+	}
+	return NewRateLimiter(bytesPerSecond)
+}
+
+// wait blocks until n bytes are allowed, or the context of the transfer is done
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	for n > 0 {
+		c := min(n, l.burst)
+		// c never exceeds the burst, so this only fails when the context ends first
+		if err := l.limiter.WaitN(ctx, c); err != nil {
+			if ctx.Err() == nil {
+				// the wait would outlast the deadline, which is reported once it passes
+				<-ctx.Done()
+			}
+			return context.Cause(ctx)
+		}
+		n -= c
+	}
+	return nil
+}
+
+func waitAll(ctx context.Context, limiters []*RateLimiter, n int) error {
+	for _, l := range limiters {
+		if err := l.wait(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*RateLimiter
+}
+
+func newThrottledReader(ctx context.Context, r io.Reader, limiters []*RateLimiter) io.Reader {
+	if len(limiters) == 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiters: limiters}
+}
+
+func (t *throttledReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	if waitErr := waitAll(t.ctx, t.limiters, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+type throttledWriterAt struct {
+	ctx      context.Context
+	w        io.WriterAt
+	limiters []*RateLimiter
+}
+
+func newThrottledWriterAt(ctx context.Context, w io.WriterAt, limiters []*RateLimiter) io.WriterAt {
+	if len(limiters) == 0 {
+		return w
+	}
+	return &throttledWriterAt{ctx: ctx, w: w, limiters: limiters}
+}
+
+func (t *throttledWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	if err := waitAll(t.ctx, t.limiters, len(b)); err != nil {
+		return 0, err
+	}
+	return t.w.WriteAt(b, off)
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is matched by errors of operations exceeding their TimeoutConfig
+var ErrTimeout = errors.New("storage operation timed out")
+
+type timeoutClass int
+
+const (
+	timeoutConnect timeoutClass = iota
+	timeoutMetadata
+	timeoutList
+	timeoutUpload
+	timeoutDownload
+	timeoutPresign
+)
+
+func (c *TimeoutConfig) get(class timeoutClass) time.Duration {
+	if c == nil {
+		return 0
+	}
+	switch class {
+	case timeoutConnect:
+		return c.Connect
+	case timeoutMetadata:
+		return c.Metadata
+	case timeoutList:
+		return c.List
+	case timeoutUpload:
+		return c.Upload
+	case timeoutDownload:
+		return c.Download
+	default:
+		return c.Presign
+	}
+}
+
+// context returns the context of an operation, canceled once the timeout of its class elapses.
+// The cancel func must be called when the operation, including reading its response, is done.
+func (c *TimeoutConfig) context(class timeoutClass) (context.Context, context.CancelFunc) {
+	return c.contextFrom(context.Background(), class)
+}
+
+// contextFrom is context for an operation also ending with parent, such as the checks of Validate
+func (c *TimeoutConfig) contextFrom(parent context.Context, class timeoutClass) (context.Context, context.CancelFunc) {
+	if d := c.get(class); d > 0 {
+		return context.WithTimeoutCause(parent, d, ErrTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// timeoutError wraps err with ErrTimeout if ctx timed out, since each sdk reports it differently
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) || !errors.Is(context.Cause(ctx), ErrTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
+
+// wrapTimeout applies timeoutError to the named error result of an operation with several return paths
+func wrapTimeout(ctx context.Context, err *error) {
+	*err = timeoutError(ctx, *err)
+}
@@ -0,0 +1,261 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newTransport returns a dedicated transport, so that each storage owns its connection pool
+// and can release it on Close without affecting other clients in the process
+func newTransport() *http.Transport {
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// newHTTPClient returns the client configured for a backend, or creates one on a dedicated transport
+func newHTTPClient(client *http.Client, proxy *ProxyConfig, tlsConf *TLSConfig, transportConf *TransportConfig) (*http.Client, error) {
+	if client != nil {
+		return client, nil
+	}
+
+	transport, err := newClientTransport(proxy, tlsConf, transportConf)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: withUserAgent(transport, transportConf)}, nil
+}
+
+// newClientTransport returns a dedicated transport with the proxy, tls and tuning settings of a backend
+func newClientTransport(proxy *ProxyConfig, tlsConf *TLSConfig, transportConf *TransportConfig) (*http.Transport, error) {
+	transport := newTransport()
+	if err := applyProxy(transport, proxy); err != nil {
+		return nil, err
+	}
+	if err := applyTLS(transport, tlsConf); err != nil {
+		return nil, err
+	}
+	applyTransportConfig(transport, transportConf)
+	return transport, nil
+}
+
+// applyTransportConfig overrides the transport defaults that are set in conf
+func applyTransportConfig(transport *http.Transport, conf *TransportConfig) {
+	if conf == nil {
+		return
+	}
+
+	if conf.DialTimeout != 0 || conf.KeepAlive != 0 {
+		// the defaults of http.DefaultTransport
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		if conf.DialTimeout != 0 {
+			dialer.Timeout = conf.DialTimeout
+		}
+		if conf.KeepAlive != 0 {
+			dialer.KeepAlive = conf.KeepAlive
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if conf.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = conf.TLSHandshakeTimeout
+	}
+	if conf.ResponseHeaderTimeout != 0 {
+		transport.ResponseHeaderTimeout = conf.ResponseHeaderTimeout
+	}
+	if conf.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = conf.IdleConnTimeout
+	}
+	if conf.MaxIdleConns != 0 {
+		transport.MaxIdleConns = conf.MaxIdleConns
+	}
+	if conf.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+	if conf.MaxConnsPerHost != 0 {
+		transport.MaxConnsPerHost = conf.MaxConnsPerHost
+	}
+	transport.DisableKeepAlives = conf.DisableKeepAlives
+	if conf.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables the automatic upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+// userAgentTransport prepends a user agent to the one set by the sdk
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func withUserAgent(transport http.RoundTripper, conf *TransportConfig) http.RoundTripper {
+	if conf == nil || conf.UserAgent == "" {
+		return transport
+	}
+	return &userAgentTransport{base: transport, userAgent: conf.UserAgent}
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	userAgent := t.userAgent
+	if sdkUserAgent := req.Header.Get("User-Agent"); sdkUserAgent != "" {
+		userAgent += " " + sdkUserAgent
+	}
+
+	// round trippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent)
+	return t.base.RoundTrip(req)
+}
+
+func (t *userAgentTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// applyProxy routes the transport through the configured proxy, except for hosts matching its exclusions
+func applyProxy(transport *http.Transport, conf *ProxyConfig) error {
+	if conf == nil {
+		return nil
+	}
+
+	if _, err := url.Parse(conf.Url); err != nil {
+		return err
+	}
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  conf.Url,
+		HTTPSProxy: conf.Url,
+		NoProxy:    strings.Join(conf.NoProxy, ","),
+	}).ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	if conf.Username != "" && conf.Password != "" {
+		password := newSecret(conf.Password)
+		if _, err := password.get(); err != nil {
+			return err
+		}
+		// resolved on each connection, so that rotated secret files are picked up
+		transport.GetProxyConnectHeader = func(context.Context, *url.URL, string) (http.Header, error) {
+			p, err := password.get()
+			if err != nil {
+				return nil, err
+			}
+			auth := fmt.Sprintf("%s:%s", conf.Username, p)
+			return http.Header{"Proxy-Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(auth))}}, nil
+		}
+	}
+
+	return nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// applyTLS sets the trusted certificate authorities, client certificate and minimum version of the transport
+func applyTLS(transport *http.Transport, conf *TLSConfig) error {
+	if conf == nil {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.MinVersion != "" {
+		version, ok := tlsVersions[conf.MinVersion]
+		if !ok {
+			return fmt.Errorf("unknown tls version %q", conf.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if conf.CAFile != "" || conf.CA != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if conf.CAFile != "" {
+			pem, err := os.ReadFile(conf.CAFile)
+			if err != nil {
+				return err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in %s", conf.CAFile)
+			}
+		}
+		if conf.CA != "" && !pool.AppendCertsFromPEM([]byte(conf.CA)) {
+			return errors.New("no certificates found in tls ca")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert := &clientCertificate{
+			certFile: newSecret(secretFilePrefix + conf.CertFile),
+			keyFile:  newSecret(secretFilePrefix + conf.KeyFile),
+		}
+		if _, err := cert.get(); err != nil {
+			return err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return nil
+}
+
+// clientCertificate loads a key pair on each handshake, so that rotated certificates are picked up
+type clientCertificate struct {
+	certFile *secret
+	keyFile  *secret
+}
+
+func (c *clientCertificate) get() (*tls.Certificate, error) {
+	certPEM, err := c.certFile.get()
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := c.keyFile.get()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
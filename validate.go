@@ -0,0 +1,201 @@
+// Copyright 2025 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	probePrefix = ".livekit-probe-"
+	// bounds the probe cleanup, which outlives the context of Validate
+	probeDeleteTimeout = 10 * time.Second
+)
+
+var (
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrProbeSkipped   = errors.New("skipped, probe object could not be written")
+	ErrProbeMismatch  = errors.New("probe object content mismatch")
+	ErrProbeNotListed = errors.New("probe object not listed")
+)
+
+// ValidationReport contains the result of each check performed by Validate.
+// A nil error means the permission is present.
+type ValidationReport struct {
+	ProbeKey string
+	Bucket   error
+	Write    error
+	Read     error
+	List     error
+	Delete   error
+}
+
+// Missing returns the names of the failed checks
+func (r *ValidationReport) Missing() []string {
+	var missing []string
+	for _, c := range r.checks() {
+		if c.err != nil {
+			missing = append(missing, c.name)
+		}
+	}
+	return missing
+}
+
+// Err returns all check failures joined together, or nil if every check passed
+func (r *ValidationReport) Err() error {
+	var errs []error
+	for _, c := range r.checks() {
+		if c.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, c.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type validationCheck struct {
+	name string
+	err  error
+}
+
+func (r *ValidationReport) checks() []validationCheck {
+	return []validationCheck{
+		{"bucket", r.Bucket},
+		{"write", r.Write},
+		{"read", r.Read},
+		{"list", r.List},
+		{"delete", r.Delete},
+	}
+}
+
+// prober is implemented by every backend, running the checks of Validate within its context
+type prober interface {
+	bucketExists(ctx context.Context) (bool, error)
+	uploadData(ctx context.Context, data []byte, storagePath, contentType string, opts ...Option) (string, int64, error)
+	downloadData(ctx context.Context, storagePath string, opts ...Option) ([]byte, error)
+	listObjects(ctx context.Context, prefix string) ([]string, error)
+	deleteObject(ctx context.Context, storagePath string) error
+}
+
+// asProber returns the prober of s, falling back to its exported methods for storages of other packages
+func asProber(s Storage) prober {
+	if p, ok := s.(prober); ok {
+		return p
+	}
+	return storageProber{s}
+}
+
+// storageProber only checks ctx between the checks
+type storageProber struct {
+	Storage
+}
+
+func (s storageProber) bucketExists(_ context.Context) (bool, error) {
+	return s.BucketExists()
+}
+
+func (s storageProber) uploadData(_ context.Context, data []byte, storagePath, contentType string, opts ...Option) (string, int64, error) {
+	return s.UploadData(data, storagePath, contentType, opts...)
+}
+
+func (s storageProber) downloadData(_ context.Context, storagePath string, opts ...Option) ([]byte, error) {
+	return s.DownloadData(storagePath, opts...)
+}
+
+func (s storageProber) listObjects(_ context.Context, prefix string) ([]string, error) {
+	return s.ListObjects(prefix)
+}
+
+func (s storageProber) deleteObject(_ context.Context, storagePath string) error {
+	return s.DeleteObject(storagePath)
+}
+
+// validate checks that the bucket exists and performs a write/read/list/delete round-trip on a probe object
+func validate(ctx context.Context, s prober) (r *ValidationReport, err error) {
+	r = &ValidationReport{
+		ProbeKey: fmt.Sprintf("%s%d", probePrefix, time.Now().UnixNano()),
+	}
+	data := []byte(r.ProbeKey)
+
+	exists, err := s.bucketExists(ctx)
+	if err != nil {
+		r.Bucket = err
+	} else if !exists {
+		r.Bucket = ErrBucketNotFound
+	}
+	if r.Bucket != nil {
+		r.Write, r.Read, r.List, r.Delete = ErrProbeSkipped, ErrProbeSkipped, ErrProbeSkipped, ErrProbeSkipped
+		return r, r.Err()
+	}
+	if err = ctx.Err(); err != nil {
+		return r, err
+	}
+
+	if _, _, err = s.uploadData(ctx, data, r.ProbeKey, "text/plain"); err != nil {
+		r.Write = err
+		r.Read, r.Delete = ErrProbeSkipped, ErrProbeSkipped
+	} else {
+		// the probe is deleted even once ctx is done, so that it is never left behind
+		defer func() {
+			deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeDeleteTimeout)
+			defer cancel()
+			r.Delete = s.deleteObject(deleteCtx, r.ProbeKey)
+			if err == nil {
+				err = r.Err()
+			}
+		}()
+	}
+	if err = ctx.Err(); err != nil {
+		return r, err
+	}
+
+	if r.Write == nil {
+		downloaded, err := s.downloadData(ctx, r.ProbeKey)
+		if err != nil {
+			r.Read = err
+		} else if !bytes.Equal(downloaded, data) {
+			r.Read = ErrProbeMismatch
+		}
+		if err = ctx.Err(); err != nil {
+			return r, err
+		}
+	}
+
+	objects, err := s.listObjects(ctx, probePrefix)
+	if err != nil {
+		r.List = err
+	} else if r.Write == nil {
+		r.List = ErrProbeNotListed
+		for _, object := range objects {
+			if strings.HasSuffix(object, r.ProbeKey) {
+				r.List = nil
+				break
+			}
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return r, err
+	}
+
+	// the probe, if written, is deleted when returning
+	if r.Write != nil {
+		return r, r.Err()
+	}
+	return r, nil
+}